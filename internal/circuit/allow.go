@@ -0,0 +1,88 @@
+package circuit
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// allow is the two-phase counterpart of call. The outcome is reported once,
+// by done or by the timer that expires the reservation, whichever comes
+// first, so an abandoned call cannot hold a half-open probe slot forever.
+func allow(m machine) (func(err error), bool) {
+	generation, rejected := m.before()
+	if rejected != nil {
+		return func(error) {}, false
+	}
+
+	o := m.config()
+	start := o.clock.Now()
+	var reported atomic.Bool
+	release := m.reservations().arm(o.clock, o.allowTimeout, func() {
+		if reported.CompareAndSwap(false, true) {
+			_ = m.after(generation, TimedOut)
+		}
+	})
+
+	return func(err error) {
+		if !reported.CompareAndSwap(false, true) {
+			return
+		}
+		release()
+		_ = m.after(generation, o.result(err, o.clock.Now().Sub(start)))
+	}, true
+}
+
+// reservations keeps the timers of the calls reserved by Allow, so closing
+// the breaker can stop them.
+type reservations struct {
+	mu     sync.Mutex
+	lastID uint64
+	stops  map[uint64]func() bool
+	closed bool
+}
+
+// arm calls expire once d has elapsed unless the returned release is called
+// first. Once closed, nothing is armed and reservations never expire.
+func (r *reservations) arm(clock Clock, d time.Duration, expire func()) (release func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return func() {}
+	}
+
+	r.lastID++
+	id := r.lastID
+	if r.stops == nil {
+		r.stops = make(map[uint64]func() bool)
+	}
+	r.stops[id] = clock.AfterFunc(d, func() {
+		r.remove(id)
+		expire()
+	})
+
+	return func() {
+		if stop := r.remove(id); stop != nil {
+			stop()
+		}
+	}
+}
+
+func (r *reservations) remove(id uint64) func() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stop := r.stops[id]
+	delete(r.stops, id)
+	return stop
+}
+
+// close stops the timers of every outstanding reservation.
+func (r *reservations) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	for _, stop := range r.stops {
+		stop()
+	}
+	r.stops = nil
+}
@@ -0,0 +1,162 @@
+package circuit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+func TestAllow(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	for name, b := range newBreakers(t, clock, WithAllowTimeout(time.Second)) {
+		done, ok := b.Allow()
+		assert.True(t, ok, name)
+		done(nil)
+
+		done, ok = b.Allow()
+		assert.True(t, ok, name)
+		done(assert.AnError)
+		assert.Equal(t, Open, b.State(), name)
+
+		// Rejected calls get a done that does nothing.
+		done, ok = b.Allow()
+		assert.False(t, ok, name)
+		done(nil)
+
+		stats := b.Stats()
+		assert.Equal(t, uint64(1), stats.Successes, name)
+		assert.Equal(t, uint64(1), stats.Failures, name)
+		assert.Equal(t, uint64(1), stats.Rejections, name)
+	}
+	assert.Zero(t, clock.Timers())
+}
+
+func TestAllowDoneTwice(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	for name, b := range newBreakers(t, clock, WithAllowTimeout(time.Second)) {
+		done, ok := b.Allow()
+		assert.True(t, ok, name)
+		done(nil)
+		done(assert.AnError)
+		done(assert.AnError)
+
+		stats := b.Stats()
+		assert.Equal(t, Closed, stats.State, name)
+		assert.Equal(t, uint64(1), stats.Requests, name)
+		assert.Equal(t, uint64(1), stats.Successes, name)
+		assert.Zero(t, stats.Failures, name)
+	}
+}
+
+func TestAllowDoneAfterStateChange(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	for name, b := range newBreakers(t, clock, WithAllowTimeout(time.Second)) {
+		slow, ok := b.Allow()
+		assert.True(t, ok, name)
+
+		assert.Equal(t, Failed, b.Call(Error(t)), name)
+		assert.Equal(t, Open, b.State(), name)
+
+		// The success belongs to the closed breaker it was admitted by.
+		slow(nil)
+		assert.Equal(t, Open, b.State(), name)
+		assert.Equal(t, uint64(1), b.Stats().Successes, name)
+	}
+}
+
+func TestAllowDoneNeverCalled(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	breakers := newBreakers(t, clock, WithAllowTimeout(time.Second))
+	for name, b := range breakers {
+		_ = b.Call(Error(t))
+		assert.Equal(t, Rejected, b.Call(Ok(t)), name)
+	}
+	clock.Advance(time.Millisecond)
+
+	abandoned := make(map[string]func(error))
+	for name, b := range breakers {
+		done, ok := b.Allow()
+		assert.True(t, ok, name)
+		assert.Equal(t, HalfOpen, b.State(), name)
+		abandoned[name] = done
+
+		_, ok = b.Allow()
+		assert.False(t, ok, name)
+	}
+
+	// The probe that never reports is timed out and releases its slot.
+	clock.Advance(time.Second)
+	for name, b := range breakers {
+		stats := b.Stats()
+		assert.Equal(t, Open, stats.State, name)
+		assert.Equal(t, uint64(1), stats.TimedOut, name)
+		assert.Equal(t, uint64(1), stats.TooManyProbes, name)
+
+		abandoned[name](nil)
+		assert.Zero(t, b.Stats().Successes, name)
+	}
+	assert.Zero(t, clock.Timers())
+}
+
+func TestAllowStoppedByClose(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithAllowTimeout(time.Second))
+	assert.NoError(t, err)
+
+	reserved, ok := cb.Allow()
+	assert.True(t, ok)
+	assert.Equal(t, 1, clock.Timers())
+	assert.NoError(t, cb.Close())
+	assert.Zero(t, clock.Timers())
+
+	// Reserved calls no longer expire, before or after Close, but still end
+	// with done.
+	done, ok := cb.Allow()
+	assert.True(t, ok)
+	assert.Zero(t, clock.Timers())
+	clock.Advance(2 * time.Second)
+	assert.Zero(t, cb.Stats().TimedOut)
+
+	reserved(nil)
+	done(assert.AnError)
+	stats := cb.Stats()
+	assert.Equal(t, uint64(1), stats.Successes)
+	assert.Equal(t, uint64(1), stats.Failures)
+}
+
+func TestAllowClassifiesOutcome(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	breakers := newBreakers(t, clock, WithAllowTimeout(time.Second), WithSlowCallThreshold(time.Millisecond), WithClassifier(ignoreCanceled),
+		WithFailureThreshold(5), WithWindowSize(10), WithMinimumCalls(5))
+	for name, b := range breakers {
+		done, _ := b.Allow()
+		done(context.Canceled)
+
+		slow, _ := b.Allow()
+		clock.Advance(2 * time.Millisecond)
+		slow(nil)
+
+		timedOut, _ := b.Allow()
+		timedOut(context.DeadlineExceeded)
+
+		stats := b.Stats()
+		assert.Equal(t, uint64(1), stats.Ignored, name)
+		assert.Equal(t, uint64(1), stats.Slow, name)
+		assert.Equal(t, uint64(1), stats.TimedOut, name)
+	}
+}
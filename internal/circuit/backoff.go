@@ -0,0 +1,35 @@
+package circuit
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Random is the source of jitter, *rand.Rand satisfies it.
+type Random interface {
+	Float64() float64
+}
+
+type globalRandom struct{}
+
+func (globalRandom) Float64() float64 {
+	return rand.Float64() //nolint:gosec
+}
+
+// backoff returns how long to stay open after trips consecutive trips.
+func (o *options) backoff(trips int) time.Duration {
+	d := o.openTimeout
+	if o.maxOpenTimeout > 0 {
+		for i := 0; i < trips && d < o.maxOpenTimeout; i++ {
+			d *= 2
+		}
+		d = min(d, o.maxOpenTimeout)
+	}
+
+	if o.jitter > 0 {
+		d -= time.Duration(float64(d) * o.jitter * o.random.Float64())
+	}
+
+	asserts(d > 0)
+	return d
+}
@@ -0,0 +1,130 @@
+package circuit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+type fixedRandom float64
+
+func (r fixedRandom) Float64() float64 {
+	return float64(r)
+}
+
+func TestBackoffDisabled(t *testing.T) {
+	t.Parallel()
+
+	o := mustOptions(t, WithOpenTimeout(time.Second))
+	for trips := range 5 {
+		assert.Equal(t, time.Second, o.backoff(trips))
+	}
+}
+
+func TestBackoffDoublesUpToCap(t *testing.T) {
+	t.Parallel()
+
+	o := mustOptions(t, WithOpenTimeout(time.Second), WithBackoff(10*time.Second))
+	assert.Equal(t, time.Second, o.backoff(0))
+	assert.Equal(t, 2*time.Second, o.backoff(1))
+	assert.Equal(t, 4*time.Second, o.backoff(2))
+	assert.Equal(t, 8*time.Second, o.backoff(3))
+	assert.Equal(t, 10*time.Second, o.backoff(4))
+	assert.Equal(t, 10*time.Second, o.backoff(1_000))
+}
+
+func TestBackoffJitter(t *testing.T) {
+	t.Parallel()
+
+	o := mustOptions(t, WithOpenTimeout(time.Second), WithBackoff(10*time.Second), WithJitter(0.5, fixedRandom(0.5)))
+	assert.Equal(t, 750*time.Millisecond, o.backoff(0))
+	assert.Equal(t, 7500*time.Millisecond, o.backoff(10))
+
+	o = mustOptions(t, WithOpenTimeout(time.Second), WithJitter(0.5, nil))
+	for range 100 {
+		d := o.backoff(0)
+		assert.LessOrEqual(t, d, time.Second)
+		assert.Greater(t, d, 500*time.Millisecond)
+	}
+}
+
+func TestBackoffInvalid(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+
+	c, err := NewTimeCB(WithClock(clock), WithOpenTimeout(time.Second), WithHalfOpenFailureThreshold(1), WithFailureThreshold(1), WithBackoff(-time.Second))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "maxOpenTimeout")
+
+	c, err = NewTimeCB(WithClock(clock), WithOpenTimeout(time.Second), WithHalfOpenFailureThreshold(1), WithFailureThreshold(1), WithBackoff(time.Millisecond))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "maxOpenTimeout")
+
+	c, err = NewTimeCB(WithClock(clock), WithOpenTimeout(time.Second), WithHalfOpenFailureThreshold(1), WithFailureThreshold(1), WithJitter(1, nil))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "jitter")
+
+	c, err = NewTimeCB(WithClock(clock), WithOpenTimeout(time.Second), WithHalfOpenFailureThreshold(1), WithFailureThreshold(1), WithJitter(-0.1, nil))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "jitter")
+
+	r, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithWindowSize(1), WithMinimumCalls(1), WithFailureRateThreshold(100), WithBackoff(time.Millisecond))
+	assert.Nil(t, r)
+	assert.ErrorContains(t, err, "maxOpenTimeout")
+
+	r, err = NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithRollingWindow(time.Minute, 60), WithMinimumCalls(1), WithFailureRateThreshold(100), WithBackoff(time.Millisecond))
+	assert.Nil(t, r)
+	assert.ErrorContains(t, err, "maxOpenTimeout")
+}
+
+func TestTimeReopensWithBackoffAndResetsOnClose(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(900*time.Millisecond), WithHalfOpenFailureThreshold(1), WithFailureThreshold(1), WithBackoff(3600*time.Millisecond))
+	assert.NoError(t, err)
+
+	// Open for 0.9s, then 1.8s, then 3.6s, then capped at 3.6s.
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	for _, ticks := range []int{1, 2, 4, 4} {
+		for range ticks {
+			assert.Equal(t, Rejected, cb.Call(Ok(t)))
+			clock.Advance(time.Second)
+		}
+		assert.Equal(t, Failed, cb.Call(Error(t)))
+		assert.Equal(t, Open, cb.State())
+	}
+
+	for range 4 {
+		clock.Advance(time.Second)
+	}
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	assert.Equal(t, Closed, cb.State())
+
+	// Closed reset the backoff, the next trip is open for 0.9s again.
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	assert.Equal(t, Rejected, cb.Call(Ok(t)))
+	clock.Advance(time.Second)
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+}
+
+func TestRateReopensWithBackoff(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(900*time.Millisecond), WithWindowSize(1), WithMinimumCalls(1), WithFailureRateThreshold(100), WithBackoff(time.Minute))
+	assert.NoError(t, err)
+
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	clock.Advance(time.Second)
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+
+	clock.Advance(time.Second)
+	assert.Equal(t, Rejected, cb.Call(Ok(t)))
+	clock.Advance(time.Second)
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	assert.Equal(t, Closed, cb.State())
+}
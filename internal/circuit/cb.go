@@ -1,7 +1,9 @@
 package circuit
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -11,16 +13,168 @@ const (
 	Closed State = iota
 	Open
 	HalfOpen
+	// ForcedOpen and ForcedClosed are set by hand, see Breaker.ForceOpen.
+	ForcedOpen
+	ForcedClosed
 )
 
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	case ForcedOpen:
+		return "forced-open"
+	case ForcedClosed:
+		return "forced-closed"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	for _, state := range allStates {
+		if s == state {
+			return []byte(s.String()), nil
+		}
+	}
+	return nil, fmt.Errorf("circuit: unknown state %d", int(s))
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	for _, state := range allStates {
+		if string(text) == state.String() {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("circuit: unknown state %q", text)
+}
+
 type Result int
 
 const (
 	Rejected Result = iota
 	Failed
 	Succeeded
+	Slow
+	TimedOut
+	Ignored
+	TooManyProbes
+)
+
+func (r Result) String() string {
+	switch r {
+	case Rejected:
+		return "rejected"
+	case Failed:
+		return "failed"
+	case Succeeded:
+		return "succeeded"
+	case Slow:
+		return "slow"
+	case TimedOut:
+		return "timed_out"
+	case Ignored:
+		return "ignored"
+	case TooManyProbes:
+		return "too_many_probes"
+	default:
+		return fmt.Sprintf("Result(%d)", int(r))
+	}
+}
+
+type Breaker interface {
+	Call(f func() error) Result
+	CallContext(ctx context.Context, f func(context.Context) error) Result
+	// Execute is Call for callers that want an error: the one returned by
+	// f unchanged, or a *RejectedError wrapping ErrOpen or ErrTooManyProbes.
+	Execute(f func() error) error
+	ExecuteContext(ctx context.Context, f func(context.Context) error) error
+	CallWithFallback(f func() error, fallback Fallback) error
+	State() State
+	Stats() Stats
+	// OnStateChange registers listener until cancel is called.
+	OnStateChange(listener StateChangeListener) (cancel func())
+	// ForceOpen rejects every call and ForceClosed admits every call without
+	// looking at its outcome, until the override is cleared by Reset. Reset
+	// leaves the breaker closed with its failure counts cleared, whether it
+	// was overridden or not.
+	ForceOpen()
+	ForceClosed()
+	Reset()
+	// Allow reserves a call for work that completes asynchronously, done
+	// reports the error it ended with as f would return it to Call. Only the
+	// first done counts, and a call whose done does not come within
+	// WithAllowTimeout is reported as TimedOut. When ok is false the call was
+	// rejected and done does nothing.
+	Allow() (done func(err error), ok bool)
+}
+
+var (
+	_ Breaker = (*CountCB)(nil)
+	_ Breaker = (*TimeCB)(nil)
+	_ Breaker = (*RateCB)(nil)
 )
 
+// machine is the state machine behind every breaker. before admits a call,
+// or rejects it when rejected is not nil, after applies the outcome of an
+// admitted one.
+type machine interface {
+	before() (generation uint64, rejected *RejectedError)
+	after(generation uint64, result Result) Result
+	config() *options
+	reservations() *reservations
+}
+
+// call runs f without holding the breaker lock, so concurrent callers are
+// not serialized behind a slow backend. The outcome is only applied if the
+// breaker is still in the generation that admitted the call. A panicking f
+// counts as a failure, so it cannot keep holding a half-open probe slot.
+func call(m machine, f func() error) (Result, *RejectedError) {
+	generation, rejected := m.before()
+	if rejected != nil {
+		return rejected.result(), rejected
+	}
+
+	completed := false
+	defer func() {
+		if !completed {
+			_ = m.after(generation, Failed)
+		}
+	}()
+	result := m.config().run(f)
+	completed = true
+	return m.after(generation, result), nil
+}
+
+// attempt is call for any Breaker. Breakers of other packages are told apart
+// by their result only, their rejections are reported as open or half-open.
+// They cannot unwrap a classified error either, so they get a plain one.
+func attempt(b Breaker, f func() error) (Result, *RejectedError) {
+	if m, ok := b.(machine); ok {
+		return call(m, f)
+	}
+	result := b.Call(func() error {
+		return unclassify(f())
+	})
+	return result, rejection(result)
+}
+
+// callContext rejects without touching the breaker when ctx is already done,
+// there is no point in spending a call slot on work nobody is waiting for.
+func callContext(ctx context.Context, b Breaker, f func(context.Context) error) Result {
+	if ctx.Err() != nil {
+		return Rejected
+	}
+	return b.Call(func() error {
+		return f(ctx)
+	})
+}
+
 func asserts(condition bool) {
 	if !condition {
 		panic("assertion failed")
@@ -1,9 +1,13 @@
 package circuit
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
 )
 
 func TestAsserts(t *testing.T) {
@@ -14,3 +18,85 @@ func TestAsserts(t *testing.T) {
 		asserts(false)
 	})
 }
+
+// newBreakers returns one of each breaker on clock, opening after one failure
+// and half-open with a single probe slot once clock advances a millisecond.
+// opts are applied last.
+func newBreakers(t *testing.T, clock *circuittest.Clock, opts ...Option) map[string]Breaker {
+	t.Helper()
+
+	opts = append([]Option{WithClock(clock), WithFailureThreshold(1), WithOpenTimeout(time.Millisecond / 2)}, opts...)
+	count, err := NewCountCB(append([]Option{WithOpenRejections(1)}, opts...)...)
+	assert.NoError(t, err)
+	timed, err := NewTimeCB(opts...)
+	assert.NoError(t, err)
+	rate, err := NewRateCB(append([]Option{WithWindowSize(1), WithMinimumCalls(1)}, opts...)...)
+	assert.NoError(t, err)
+
+	return map[string]Breaker{"count": count, "time": timed, "rate": rate}
+}
+
+func TestCallContextPassesContext(t *testing.T) {
+	t.Parallel()
+
+	type key struct{}
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.WithValue(context.Background(), key{}, name)
+
+			result := b.CallContext(ctx, func(ctx context.Context) error {
+				assert.Equal(t, name, ctx.Value(key{}))
+				return nil
+			})
+			assert.Equal(t, Succeeded, result)
+			assert.Equal(t, Closed, b.State())
+		})
+	}
+}
+
+func TestCallContextDoneRejectsImmediately(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			called := false
+			result := b.CallContext(ctx, func(context.Context) error {
+				called = true
+				return nil
+			})
+			assert.Equal(t, Rejected, result)
+			assert.False(t, called)
+			assert.Equal(t, Closed, b.State())
+		})
+	}
+}
+
+func TestCallContextFailureCounts(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			result := b.CallContext(context.Background(), func(context.Context) error {
+				return errors.New("error")
+			})
+			assert.Equal(t, Failed, result)
+			assert.Equal(t, Open, b.State())
+		})
+	}
+}
+
+func TestStateString(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "closed", Closed.String())
+	assert.Equal(t, "open", Open.String())
+	assert.Equal(t, "half-open", HalfOpen.String())
+	assert.Equal(t, "forced-open", ForcedOpen.String())
+	assert.Equal(t, "forced-closed", ForcedClosed.String())
+	assert.Equal(t, "State(42)", State(42).String())
+}
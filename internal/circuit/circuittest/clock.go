@@ -0,0 +1,115 @@
+// Package circuittest provides helpers for testing code built on package
+// circuit.
+package circuittest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a manual clock that implements circuit.Clock. Time only moves
+// through Advance and Set, which run the functions scheduled with AfterFunc
+// that became due before returning, so tests never have to sleep.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers []*timer
+}
+
+type timer struct {
+	at  time.Time
+	seq uint64
+	f   func()
+}
+
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d and runs the timers that became due.
+// It is safe to call from concurrent goroutines, every call adds its d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+	c.fire()
+}
+
+// Set moves the clock to now, backwards too, and runs the timers that became
+// due.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	c.mu.Unlock()
+	c.fire()
+}
+
+// AfterFunc schedules f to run once the clock reaches d from now and returns
+// a function that cancels it, reporting whether it did. Unlike
+// time.AfterFunc, f runs on the goroutine that moves the clock, and a d <= 0
+// only fires on the next Advance or Set.
+func (c *Clock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	t := &timer{at: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, t)
+
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.remove(t)
+	}
+}
+
+// Timers returns how many functions are scheduled and not run nor stopped
+// yet, handy to check that code under test does not leak timers.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// fire runs the due timers one by one, earliest first and in the order they
+// were scheduled on ties, without holding the lock so they may schedule
+// timers or read the clock themselves.
+func (c *Clock) fire() {
+	for {
+		c.mu.Lock()
+		var next *timer
+		for _, t := range c.timers {
+			if t.at.After(c.now) {
+				continue
+			}
+			if next == nil || t.at.Before(next.at) || (t.at.Equal(next.at) && t.seq < next.seq) {
+				next = t
+			}
+		}
+		if next == nil {
+			c.mu.Unlock()
+			return
+		}
+		c.remove(next)
+		c.mu.Unlock()
+
+		next.f()
+	}
+}
+
+func (c *Clock) remove(t *timer) bool {
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package circuittest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockAdvanceAndSet(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewClock(start)
+	assert.Equal(t, start, clock.Now())
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), clock.Now())
+
+	clock.Set(start.Add(-time.Hour))
+	assert.Equal(t, start.Add(-time.Hour), clock.Now())
+}
+
+func TestClockConcurrentAdvance(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	clock := NewClock(start)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				clock.Advance(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, start.Add(800*time.Millisecond), clock.Now())
+}
+
+func TestClockAfterFunc(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	clock := NewClock(start)
+
+	var fired []string
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, "b") })
+	clock.AfterFunc(time.Second, func() { fired = append(fired, "a") })
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, "c") })
+	assert.Equal(t, 3, clock.Timers())
+
+	clock.Advance(999 * time.Millisecond)
+	assert.Empty(t, fired)
+
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, []string{"a"}, fired)
+
+	clock.Set(start.Add(time.Hour))
+	assert.Equal(t, []string{"a", "b", "c"}, fired)
+	assert.Zero(t, clock.Timers())
+}
+
+func TestClockAfterFuncStop(t *testing.T) {
+	t.Parallel()
+
+	clock := NewClock(time.Now())
+
+	fired := false
+	stop := clock.AfterFunc(time.Second, func() { fired = true })
+	assert.True(t, stop())
+	assert.False(t, stop())
+
+	clock.Advance(time.Minute)
+	assert.False(t, fired)
+
+	stop = clock.AfterFunc(time.Second, func() { fired = true })
+	clock.Advance(time.Minute)
+	assert.True(t, fired)
+	assert.False(t, stop())
+}
+
+func TestClockAfterFuncReschedules(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	clock := NewClock(start)
+
+	// Timers run without the clock locked, so they may read it and schedule
+	// more timers. Those only run once they are due.
+	var ticks []time.Time
+	var tick func()
+	tick = func() {
+		ticks = append(ticks, clock.Now())
+		clock.AfterFunc(time.Second, tick)
+	}
+	clock.AfterFunc(time.Second, tick)
+
+	clock.Advance(time.Second)
+	clock.Advance(1500 * time.Millisecond)
+	assert.Equal(t, []time.Time{start.Add(time.Second), start.Add(2500 * time.Millisecond)}, ticks)
+	assert.Equal(t, 1, clock.Timers())
+
+	// A timer with no delay left waits for the clock to move.
+	fired := false
+	clock.AfterFunc(0, func() { fired = true })
+	assert.False(t, fired)
+	clock.Advance(0)
+	assert.True(t, fired)
+}
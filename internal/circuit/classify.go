@@ -0,0 +1,109 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota
+	OutcomeFailure
+	OutcomeIgnore
+)
+
+// Classifier decides what an error returned by the protected function says
+// about the health of the backend.
+type Classifier func(err error) Outcome
+
+// DefaultClassifier counts every non-nil error as a failure.
+func DefaultClassifier(err error) Outcome {
+	if err != nil {
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+// classified is returned by protected functions that classify their own
+// outcome, such as the one run by Transport, bypassing the Classifier.
+type classified struct {
+	outcome Outcome
+	err     error
+}
+
+func (c *classified) Error() string {
+	return fmt.Sprintf("circuit: classified %d: %v", int(c.outcome), c.err)
+}
+
+// errClassifiedFailure stands for a failure classified without an error, such
+// as a 5xx response served by the handler of Middleware.
+var errClassifiedFailure = errors.New("circuit: call classified as a failure")
+
+// unclassify turns a classified error back into what a Classifier of another
+// package expects: nil on success and an error on failure. Ignored calls have
+// no such equivalent and keep their error.
+func unclassify(err error) error {
+	var c *classified
+	if !errors.As(err, &c) {
+		return err
+	}
+	switch c.outcome {
+	case OutcomeSuccess:
+		return nil
+	case OutcomeFailure:
+		if c.err == nil {
+			return errClassifiedFailure
+		}
+		return c.err
+	default:
+		return c.err
+	}
+}
+
+func (o *options) classify(err error) (Outcome, error) {
+	var c *classified
+	if errors.As(err, &c) {
+		return c.outcome, c.err
+	}
+	return o.classifier(err), err
+}
+
+// run calls f and classifies its outcome, timing it through the clock.
+func (o *options) run(f func() error) Result {
+	start := o.clock.Now()
+	err := f()
+	return o.result(err, o.clock.Now().Sub(start))
+}
+
+// result classifies the outcome of a call that returned err after elapsed.
+func (o *options) result(err error, elapsed time.Duration) Result {
+	outcome, err := o.classify(err)
+	switch outcome {
+	case OutcomeIgnore:
+		return Ignored
+	case OutcomeFailure:
+		if err != nil && isTimeout(err) {
+			return TimedOut
+		}
+		return Failed
+	case OutcomeSuccess:
+		if o.slowCallThreshold > 0 && elapsed > o.slowCallThreshold {
+			return Slow
+		}
+		return Succeeded
+	default:
+		panic("unreachable")
+	}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
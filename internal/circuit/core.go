@@ -0,0 +1,137 @@
+package circuit
+
+import "sync"
+
+// core is embedded in every breaker and holds what they all share, guarded
+// by mu. forget drops the failure counts of the breaker on an override.
+type core struct {
+	mu         sync.Mutex
+	notifier   notifier
+	counters   counters
+	opts       options
+	state      State
+	generation uint64
+	probes     int
+	shared     tripCache
+	writer     tripWriter
+	reserved   reservations
+	forget     func()
+}
+
+func (c *core) setState(state State) {
+	c.notifier.emit(c.state, state)
+	c.state = state
+	c.generation++
+	c.probes = 0
+	c.counters.transition(c.opts.clock.Now())
+}
+
+// restoreState moves to the state of a snapshot, which is not a transition
+// of its own and keeps the last one of the snapshot.
+func (c *core) restoreState(state State) {
+	if c.state != state {
+		c.notifier.emit(c.state, state)
+	}
+	c.state = state
+	c.generation++
+	c.probes = 0
+}
+
+func (c *core) config() *options {
+	return &c.opts
+}
+
+func (c *core) reservations() *reservations {
+	return &c.reserved
+}
+
+// reject counts a call rejected with result, Rejected or TooManyProbes, in
+// the current state.
+func (c *core) reject(result Result) *RejectedError {
+	c.counters.reject(result)
+	if result == TooManyProbes {
+		return &RejectedError{State: c.state, Err: ErrTooManyProbes}
+	}
+	return &RejectedError{State: c.state, Err: ErrOpen}
+}
+
+// newer reports whether trip, loaded from the store before taking the lock,
+// was decided after the last transition of the breaker.
+func (c *core) newer(trip Trip, ok bool) bool {
+	return ok && trip.OpenAt.After(c.counters.stats.LastTransition)
+}
+
+// settle reports whether the outcome of a call admitted in generation still
+// drives the breaker, and gives back the probe slot it held if any.
+func (c *core) settle(generation uint64) bool {
+	// The state changed while f was running, the outcome belongs to a
+	// previous generation and must not drive the current one.
+	if generation != c.generation {
+		return false
+	}
+
+	if c.state == HalfOpen {
+		c.probes--
+		asserts(c.probes >= 0)
+	}
+	return true
+}
+
+// probe takes one of the half-open probe slots, false means they are all in
+// flight already.
+func (c *core) probe() bool {
+	if c.probes >= c.opts.maxHalfOpenProbes {
+		return false
+	}
+	c.probes++
+	return true
+}
+
+// unlock releases the lock and then delivers the transitions and writes to
+// the store made while holding it.
+func (c *core) unlock() {
+	c.mu.Unlock()
+	c.writer.flush(&c.opts)
+	c.notifier.flush()
+}
+
+func (c *core) ForceOpen() {
+	c.override(ForcedOpen)
+}
+
+func (c *core) ForceClosed() {
+	c.override(ForcedClosed)
+}
+
+func (c *core) Reset() {
+	c.override(Closed)
+}
+
+// override moves to state by hand, from whatever state the breaker was in,
+// and starts counting failures afresh.
+func (c *core) override(state State) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.state != state {
+		c.setState(state)
+	}
+	c.forget()
+	c.counters.override()
+}
+
+func (c *core) OnStateChange(listener StateChangeListener) func() {
+	return c.notifier.subscribe(listener)
+}
+
+func (c *core) Stats() Stats {
+	c.mu.Lock()
+	defer c.unlock()
+	return c.counters.snapshot(c.state)
+}
+
+func (c *core) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
@@ -1,77 +1,160 @@
 package circuit
 
-import (
-	"fmt"
-)
+import "context"
 
 type CountCB struct {
-	state                   State
-	closedFailures          uint8
-	closedFailuresThreshold uint8
-	halfOpenAttempts        uint8
-	halfOpenThreshold       uint8
+	core
+	closedFailures          int
+	closedFailuresThreshold int
+	halfOpenAttempts        int
+	halfOpenThreshold       int
 }
 
-func NewCountCB(failureTreshold, halfOpenThreshold uint8) (*CountCB, error) {
-	if failureTreshold <= 0 {
-		return nil, fmt.Errorf("failureThreshold: %q <= 0", failureTreshold)
+func NewCountCB(opts ...Option) (*CountCB, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, err
 	}
 
-	if halfOpenThreshold <= 0 {
-		return nil, fmt.Errorf("halfOpenThreshold: %q <= 0", failureTreshold)
-	}
-
-	return &CountCB{
-		state:                   Closed,
+	c := &CountCB{
+		core:                    core{opts: o, state: Closed},
 		closedFailures:          0,
-		closedFailuresThreshold: failureTreshold,
+		closedFailuresThreshold: o.failureThreshold,
 		halfOpenAttempts:        0,
-		halfOpenThreshold:       halfOpenThreshold,
-	}, nil
+		halfOpenThreshold:       o.openRejections,
+	}
+	c.forget = func() {
+		c.closedFailures = 0
+		c.halfOpenAttempts = 0
+	}
+	return c, nil
 }
 
 func (c *CountCB) Call(f func() error) Result {
-	switch c.State() {
+	result, _ := call(c, f)
+	return result
+}
+
+func (c *CountCB) CallContext(ctx context.Context, f func(context.Context) error) Result {
+	return callContext(ctx, c, f)
+}
+
+func (c *CountCB) Execute(f func() error) error {
+	return execute(c, f)
+}
+
+func (c *CountCB) ExecuteContext(ctx context.Context, f func(context.Context) error) error {
+	return executeContext(ctx, c, f)
+}
+
+func (c *CountCB) CallWithFallback(f func() error, fallback Fallback) error {
+	return callWithFallback(c, f, fallback)
+}
+
+func (c *CountCB) Allow() (func(err error), bool) {
+	return allow(c)
+}
+
+func (c *CountCB) before() (uint64, *RejectedError) {
+	trip, ok := c.shared.load(&c.opts)
+	c.mu.Lock()
+	defer c.unlock()
+
+	switch c.state {
 	case Closed:
 		asserts(c.closedFailures < c.closedFailuresThreshold)
 		asserts(c.halfOpenAttempts == 0)
 
-		if err := f(); err != nil {
-			c.closedFailures++
-			if c.closedFailures == c.closedFailuresThreshold {
-				c.state = Open
-			}
-			return Failed
+		if !c.adopt(trip, ok) {
+			c.counters.admit()
+			return c.generation, nil
 		}
-		c.closedFailures = 0
-		return Succeeded
+		fallthrough
 	case Open:
 		asserts(c.closedFailures == c.closedFailuresThreshold)
 		asserts(c.halfOpenAttempts < c.halfOpenThreshold)
 
+		rejected := c.reject(Rejected)
 		c.halfOpenAttempts++
 		if c.halfOpenAttempts == c.halfOpenThreshold {
-			c.state = HalfOpen
+			c.setState(HalfOpen)
 			c.halfOpenAttempts = 0
 		}
-		return Rejected
+		return c.generation, rejected
 	case HalfOpen:
 		asserts(c.closedFailures == c.closedFailuresThreshold)
 		asserts(c.halfOpenAttempts < c.halfOpenThreshold)
 
-		if err := f(); err != nil {
-			c.state = Open
+		if !c.probe() {
+			return c.generation, c.reject(TooManyProbes)
+		}
+		c.counters.admit()
+		return c.generation, nil
+	case ForcedOpen:
+		return c.generation, c.reject(Rejected)
+	case ForcedClosed:
+		c.counters.admit()
+		return c.generation, nil
+	default:
+		panic("unreachable")
+	}
+}
+
+func (c *CountCB) after(generation uint64, result Result) Result {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.counters.complete(result, result != Succeeded)
+	if !c.settle(generation) || result == Ignored {
+		return result
+	}
+
+	switch c.state {
+	case Closed:
+		asserts(c.closedFailures < c.closedFailuresThreshold)
+		asserts(c.halfOpenAttempts == 0)
+
+		if result != Succeeded {
+			c.closedFailures++
+			if c.closedFailures == c.closedFailuresThreshold {
+				c.open()
+			}
+			return result
+		}
+		c.closedFailures = 0
+		return result
+	case HalfOpen:
+		asserts(c.closedFailures == c.closedFailuresThreshold)
+		asserts(c.halfOpenAttempts < c.halfOpenThreshold)
+
+		if result != Succeeded {
+			c.open()
 			c.halfOpenAttempts = 0
-			return Failed
+			return result
 		}
-		c.state = Closed
+		c.setState(Closed)
 		c.closedFailures = 0
-		return Succeeded
+		c.writer.delete(&c.opts)
+		return result
+	case ForcedClosed:
+		return result
 	default:
 		panic("unreachable")
 	}
 }
 
-func (c *CountCB) State() State {
-	return c.state
+func (c *CountCB) open() {
+	c.setState(Open)
+	c.writer.save(&c.opts, Trip{OpenAt: c.opts.clock.Now()})
+}
+
+// adopt opens the breaker on a trip shared through the store since it last
+// changed state.
+func (c *CountCB) adopt(trip Trip, ok bool) bool {
+	if !c.newer(trip, ok) {
+		return false
+	}
+	c.setState(Open)
+	c.closedFailures = c.closedFailuresThreshold
+	return true
 }
@@ -8,18 +8,18 @@ import (
 
 func TestNewInvalidCircuitBreaker(t *testing.T) {
 	t.Parallel()
-	c, err := NewCountCB(0, 1)
+	c, err := NewCountCB(WithFailureThreshold(0), WithOpenRejections(1))
 	assert.Nil(t, c)
 	assert.ErrorContains(t, err, "failureThreshold")
 
-	c, err = NewCountCB(1, 0)
+	c, err = NewCountCB(WithFailureThreshold(1), WithOpenRejections(0))
 	assert.Nil(t, c)
-	assert.ErrorContains(t, err, "halfOpenThreshold")
+	assert.ErrorContains(t, err, "openRejections")
 }
 
 func TestNewvalidCircuitBreakerIsClosed(t *testing.T) {
 	t.Parallel()
-	c, err := NewCountCB(2, 1)
+	c, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(1))
 	assert.NotNil(t, c)
 	assert.NoError(t, err)
 	assert.Equal(t, c.State(), Closed)
@@ -27,7 +27,7 @@ func TestNewvalidCircuitBreakerIsClosed(t *testing.T) {
 
 func TestClosedSuccess(t *testing.T) {
 	t.Parallel()
-	c, err := NewCountCB(2, 1)
+	c, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(1))
 	assert.NotNil(t, c)
 	assert.NoError(t, err)
 	assert.Equal(t, c.State(), Closed)
@@ -39,7 +39,7 @@ func TestClosedSuccess(t *testing.T) {
 
 func TestClosedFailureStaysClosed(t *testing.T) {
 	t.Parallel()
-	c, err := NewCountCB(2, 1)
+	c, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(1))
 	assert.NotNil(t, c)
 	assert.NoError(t, err)
 	assert.Equal(t, c.State(), Closed)
@@ -51,7 +51,7 @@ func TestClosedFailureStaysClosed(t *testing.T) {
 
 func TestClosedToOpen(t *testing.T) {
 	t.Parallel()
-	c, err := NewCountCB(2, 1)
+	c, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(1))
 	assert.NotNil(t, c)
 	assert.NoError(t, err)
 	assert.Equal(t, c.State(), Closed)
@@ -67,7 +67,7 @@ func TestClosedToOpen(t *testing.T) {
 
 func TestOpenRejectsCalls(t *testing.T) {
 	t.Parallel()
-	c, err := NewCountCB(2, 2)
+	c, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(2))
 	assert.NotNil(t, c)
 	assert.NoError(t, err)
 	assert.Equal(t, c.State(), Closed)
@@ -87,7 +87,7 @@ func TestOpenRejectsCalls(t *testing.T) {
 
 func TestOpenToHalfOpen(t *testing.T) {
 	t.Parallel()
-	c, err := NewCountCB(2, 1)
+	c, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(1))
 	assert.NotNil(t, c)
 	assert.NoError(t, err)
 	assert.Equal(t, c.State(), Closed)
@@ -107,7 +107,7 @@ func TestOpenToHalfOpen(t *testing.T) {
 
 func TestHalfOpenSuccessToClosed(t *testing.T) {
 	t.Parallel()
-	c, err := NewCountCB(2, 1)
+	c, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(1))
 	assert.NotNil(t, c)
 	assert.NoError(t, err)
 	assert.Equal(t, c.State(), Closed)
@@ -131,7 +131,7 @@ func TestHalfOpenSuccessToClosed(t *testing.T) {
 
 func TestHalfOpenFailureOpen(t *testing.T) {
 	t.Parallel()
-	c, err := NewCountCB(2, 1)
+	c, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(1))
 	assert.NotNil(t, c)
 	assert.NoError(t, err)
 	assert.Equal(t, c.State(), Closed)
@@ -152,3 +152,32 @@ func TestHalfOpenFailureOpen(t *testing.T) {
 	assert.Equal(t, result, Failed)
 	assert.Equal(t, c.State(), Open)
 }
+
+func TestStaleOutcomeIsIgnored(t *testing.T) {
+	t.Parallel()
+	c, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(1))
+	assert.NotNil(t, c)
+	assert.NoError(t, err)
+
+	result := c.Call(func() error {
+		assert.Equal(t, c.Call(Error(t)), Failed)
+		assert.Equal(t, c.Call(Error(t)), Failed)
+		assert.Equal(t, c.State(), Open)
+		return nil
+	})
+	assert.Equal(t, result, Succeeded)
+	assert.Equal(t, c.State(), Open)
+}
+
+func TestCountRejectionBeforeHalfOpenReportsOpen(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB(WithFailureThreshold(1), WithOpenRejections(1))
+	assert.NoError(t, err)
+	assert.Equal(t, Failed, c.Call(Error(t)))
+
+	var rejected *RejectedError
+	assert.ErrorAs(t, c.Execute(Ok(t)), &rejected)
+	assert.Equal(t, Open, rejected.State)
+	assert.Equal(t, HalfOpen, c.State())
+}
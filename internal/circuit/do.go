@@ -0,0 +1,89 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrOpen          = errors.New("circuit: breaker is open")
+	ErrTooManyProbes = errors.New("circuit: too many half-open probes")
+)
+
+// RejectedError is returned when the breaker did not run the protected
+// function. It wraps ErrOpen or ErrTooManyProbes, State is the state the
+// breaker rejected the call in, ForcedOpen for a breaker isolated by hand.
+type RejectedError struct {
+	State State
+	Err   error
+}
+
+func (e *RejectedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RejectedError) result() Result {
+	if e.Err == ErrTooManyProbes {
+		return TooManyProbes
+	}
+	return Rejected
+}
+
+// rejection describes a rejected result, nil for the results of calls that
+// ran.
+func rejection(result Result) *RejectedError {
+	switch result {
+	case Rejected:
+		return &RejectedError{State: Open, Err: ErrOpen}
+	case TooManyProbes:
+		return &RejectedError{State: HalfOpen, Err: ErrTooManyProbes}
+	default:
+		return nil
+	}
+}
+
+func execute(m machine, f func() error) error {
+	var err error
+	_, rejected := call(m, func() error {
+		err = f()
+		return err
+	})
+	if rejected != nil {
+		return rejected
+	}
+	return err
+}
+
+// executeContext returns ctx.Err() without touching the breaker when ctx is
+// already done, see callContext.
+func executeContext(ctx context.Context, b Breaker, f func(context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.Execute(func() error {
+		return f(ctx)
+	})
+}
+
+// Do runs f through b and returns its value. When b rejects the call the
+// zero value is returned together with a *RejectedError, otherwise the error
+// is the one returned by f.
+func Do[T any](b Breaker, f func() (T, error)) (T, Result, error) {
+	var (
+		value T
+		err   error
+	)
+	result, rejected := attempt(b, func() error {
+		value, err = f()
+		return err
+	})
+	if rejected != nil {
+		var zero T
+		return zero, result, rejected
+	}
+	return value, result, err
+}
@@ -0,0 +1,143 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+func TestDoReturnsValue(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			value, result, err := Do(b, func() (int, error) {
+				return 42, nil
+			})
+			assert.Equal(t, 42, value)
+			assert.Equal(t, Succeeded, result)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDoKeepsOriginalError(t *testing.T) {
+	t.Parallel()
+
+	original := errors.New("backend down")
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			value, result, err := Do(b, func() (string, error) {
+				return "partial", original
+			})
+			assert.Equal(t, "partial", value)
+			assert.Equal(t, Failed, result)
+			assert.Same(t, original, err)
+		})
+	}
+}
+
+func TestDoRejectedError(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, Failed, b.Call(Error(t)))
+			assert.Equal(t, Open, b.State())
+
+			called := false
+			value, result, err := Do(b, func() (int, error) {
+				called = true
+				return 42, nil
+			})
+			assert.False(t, called)
+			assert.Equal(t, 0, value)
+			assert.Equal(t, Rejected, result)
+
+			var rejected *RejectedError
+			assert.ErrorAs(t, err, &rejected)
+			assert.Equal(t, Open, rejected.State)
+			assert.ErrorContains(t, err, "open")
+		})
+	}
+}
+
+func TestExecutePassesErrorsThrough(t *testing.T) {
+	t.Parallel()
+
+	original := errors.New("backend down")
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.NoError(t, b.Execute(Ok(t)))
+
+			err := b.Execute(func() error {
+				return fmt.Errorf("query: %w", original)
+			})
+			assert.ErrorIs(t, err, original)
+			assert.NotErrorIs(t, err, ErrOpen)
+			assert.Equal(t, "query: backend down", err.Error())
+
+			err = b.Execute(Ok(t))
+			assert.ErrorIs(t, err, ErrOpen)
+			assert.NotErrorIs(t, err, ErrTooManyProbes)
+
+			var rejected *RejectedError
+			assert.ErrorAs(t, err, &rejected)
+			assert.Equal(t, Open, rejected.State)
+			assert.EqualError(t, err, "circuit: breaker is open")
+		})
+	}
+}
+
+func TestExecuteTooManyProbes(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newHalfOpenBreakers(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := b.Execute(func() error {
+				err := b.Execute(Ok(t))
+				assert.ErrorIs(t, err, ErrTooManyProbes)
+
+				var rejected *RejectedError
+				assert.ErrorAs(t, err, &rejected)
+				assert.Equal(t, HalfOpen, rejected.State)
+				return nil
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestExecuteContext(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+
+			err := b.ExecuteContext(ctx, func(ctx context.Context) error {
+				return ctx.Err()
+			})
+			assert.NoError(t, err)
+
+			cancel()
+			err = b.ExecuteContext(ctx, func(context.Context) error {
+				t.Fatal("called with a done context")
+				return nil
+			})
+			assert.ErrorIs(t, err, context.Canceled)
+			assert.Equal(t, Closed, b.State())
+		})
+	}
+}
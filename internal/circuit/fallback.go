@@ -0,0 +1,48 @@
+package circuit
+
+import "fmt"
+
+type FallbackReason int
+
+const (
+	FallbackRejected FallbackReason = iota
+	FallbackFailed
+)
+
+func (r FallbackReason) String() string {
+	switch r {
+	case FallbackRejected:
+		return "rejected"
+	case FallbackFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("FallbackReason(%d)", int(r))
+	}
+}
+
+// Fallback serves a degraded response when the protected function was
+// rejected or failed. err is the *RejectedError or the error of the protected
+// function.
+type Fallback func(reason FallbackReason, err error) error
+
+// callWithFallback runs fallback outside the breaker once f was rejected,
+// failed or timed out, so whatever the fallback returns never counts against
+// the breaker. Slow calls did complete and keep their outcome, even when the
+// breaker counts them against the backend, and ignored errors are returned
+// as is.
+func callWithFallback(m machine, f func() error, fallback Fallback) error {
+	var err error
+	result, rejected := call(m, func() error {
+		err = f()
+		return err
+	})
+
+	switch result {
+	case Rejected, TooManyProbes:
+		return fallback(FallbackRejected, rejected)
+	case Failed, TimedOut:
+		return fallback(FallbackFailed, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,143 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+func TestFallbackReasonString(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "rejected", FallbackRejected.String())
+	assert.Equal(t, "failed", FallbackFailed.String())
+	assert.Equal(t, "FallbackReason(42)", FallbackReason(42).String())
+}
+
+func TestFallbackNotCalledOnSuccess(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := b.CallWithFallback(Ok(t), func(FallbackReason, error) error {
+				t.Fatal("fallback called")
+				return nil
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFallbackOnFailureThenRejection(t *testing.T) {
+	t.Parallel()
+
+	original := errors.New("backend down")
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var reasons []FallbackReason
+			var causes []error
+			fallback := func(reason FallbackReason, err error) error {
+				reasons = append(reasons, reason)
+				causes = append(causes, err)
+				return nil
+			}
+
+			assert.NoError(t, b.CallWithFallback(func() error { return original }, fallback))
+			assert.Equal(t, Open, b.State())
+			assert.NoError(t, b.CallWithFallback(Ok(t), fallback))
+
+			assert.Equal(t, []FallbackReason{FallbackFailed, FallbackRejected}, reasons)
+			assert.Same(t, original, causes[0])
+			assert.ErrorIs(t, causes[1], ErrOpen)
+		})
+	}
+}
+
+func TestFallbackErrorDoesNotCount(t *testing.T) {
+	t.Parallel()
+
+	errFallback := errors.New("cache miss")
+	c, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(2))
+	assert.NoError(t, err)
+
+	err = c.CallWithFallback(Error(t), func(FallbackReason, error) error {
+		return errFallback
+	})
+	assert.Same(t, errFallback, err)
+	assert.Equal(t, Closed, c.State())
+
+	// One more failure is needed, the fallback error was not counted.
+	err = c.CallWithFallback(Ok(t), func(FallbackReason, error) error {
+		return errFallback
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, Failed, c.Call(Error(t)))
+	assert.Equal(t, Closed, c.State())
+}
+
+func TestFallbackOnTimeoutAndTooManyProbes(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithFailureThreshold(1))
+	assert.NoError(t, err)
+
+	var reasons []FallbackReason
+	fallback := func(reason FallbackReason, _ error) error {
+		reasons = append(reasons, reason)
+		return nil
+	}
+
+	timeout := func() error { return context.DeadlineExceeded }
+	assert.NoError(t, cb.CallWithFallback(timeout, fallback))
+	clock.Advance(2 * time.Millisecond)
+
+	err = cb.CallWithFallback(func() error {
+		assert.NoError(t, cb.CallWithFallback(Ok(t), func(reason FallbackReason, err error) error {
+			assert.ErrorIs(t, err, ErrTooManyProbes)
+			return fallback(reason, err)
+		}))
+		return nil
+	}, fallback)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []FallbackReason{FallbackFailed, FallbackRejected}, reasons)
+}
+
+func TestFallbackSkipsSlowCalls(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithSlowCallThreshold(time.Second), WithFailureThreshold(1))
+	assert.NoError(t, err)
+
+	err = cb.CallWithFallback(func() error {
+		clock.Advance(2 * time.Second)
+		return nil
+	}, func(FallbackReason, error) error {
+		t.Fatal("fallback called")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), cb.Stats().Slow)
+	assert.Equal(t, Open, cb.State())
+}
+
+func TestFallbackSkipsIgnoredErrors(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB(WithClassifier(ignoreCanceled))
+	assert.NoError(t, err)
+
+	err = c.CallWithFallback(func() error { return context.Canceled }, func(FallbackReason, error) error {
+		t.Fatal("fallback called")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,90 @@
+//go:build unix
+
+package circuit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// FileStore shares trips between the processes of a host through a JSON file
+// guarded by flock.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore keeps the trips in the file at path, created on first use.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) Load(name string) (Trip, bool, error) {
+	var (
+		trip Trip
+		ok   bool
+	)
+	err := s.update(syscall.LOCK_SH, func(trips map[string]Trip) bool {
+		trip, ok = trips[name]
+		return false
+	})
+	return trip, ok, err
+}
+
+func (s *FileStore) Save(name string, trip Trip) error {
+	return s.update(syscall.LOCK_EX, func(trips map[string]Trip) bool {
+		trips[name] = trip
+		return true
+	})
+}
+
+func (s *FileStore) Delete(name string) error {
+	return s.update(syscall.LOCK_EX, func(trips map[string]Trip) bool {
+		_, ok := trips[name]
+		delete(trips, name)
+		return ok
+	})
+}
+
+// update reads the trips holding the flock how and writes them back when f
+// reports it changed them. Closing the file releases the lock.
+func (s *FileStore) update(how int, f func(trips map[string]Trip) bool) (err error) {
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0o600) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	if err := syscall.Flock(int(file.Fd()), how); err != nil { //nolint:gosec
+		return err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	trips := make(map[string]Trip)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &trips); err != nil {
+			return fmt.Errorf("circuit: store %s: %w", s.path, err)
+		}
+	}
+
+	if !f(trips) {
+		return nil
+	}
+
+	if data, err = json.Marshal(trips); err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = file.WriteAt(data, 0)
+	return err
+}
@@ -0,0 +1,98 @@
+//go:build unix
+
+package circuit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "trips.json")
+	store := NewFileStore(path)
+
+	_, ok, err := store.Load("db")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	trip := Trip{OpenAt: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), OpenFor: time.Minute}
+	assert.NoError(t, store.Save("db", trip))
+
+	// Another process opening the same file sees the trip.
+	loaded, ok, err := NewFileStore(path).Load("db")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, trip.OpenAt.Equal(loaded.OpenAt))
+	assert.Equal(t, trip.OpenFor, loaded.OpenFor)
+
+	assert.NoError(t, store.Delete("db"))
+	assert.NoError(t, store.Delete("db"))
+	_, ok, err = store.Load("db")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestFileStoreConcurrentWriters(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "trips.json")
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store := NewFileStore(path)
+			for j := range 10 {
+				assert.NoError(t, store.Save(fmt.Sprintf("backend-%d-%d", i, j), Trip{OpenAt: time.Now()}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	store := NewFileStore(path)
+	for i := range 8 {
+		for j := range 10 {
+			_, ok, err := store.Load(fmt.Sprintf("backend-%d-%d", i, j))
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+	}
+}
+
+func TestFileStoreErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.json")
+	assert.NoError(t, os.WriteFile(corrupt, []byte("{"), 0o600))
+	_, _, err := NewFileStore(corrupt).Load("db")
+	assert.ErrorContains(t, err, "corrupt.json")
+
+	_, _, err = NewFileStore(filepath.Join(dir, "missing", "trips.json")).Load("db")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestTimeCBSharesTripsThroughFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "trips.json")
+	opts := func() []Option {
+		return []Option{WithFailureThreshold(1), WithStore(NewFileStore(path)), WithName("db")}
+	}
+	replica1, err := NewTimeCB(opts()...)
+	assert.NoError(t, err)
+	replica2, err := NewTimeCB(opts()...)
+	assert.NoError(t, err)
+
+	_ = replica1.Call(Error(t))
+	assert.Equal(t, Rejected, replica2.Call(Ok(t)))
+}
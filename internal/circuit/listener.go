@@ -0,0 +1,111 @@
+package circuit
+
+import (
+	"slices"
+	"sync"
+)
+
+// StateChangeListener is called once for every transition of a breaker.
+// Listeners run in registration order, one transition at a time and in the
+// order the transitions happened. They are never called concurrently and
+// never while the breaker lock is held, so they may call back into the
+// breaker. The goroutine delivering a transition is not necessarily the one
+// that caused it.
+type StateChangeListener func(from, to State)
+
+type transition struct {
+	from State
+	to   State
+}
+
+type subscription struct {
+	id     uint64
+	listen StateChangeListener
+}
+
+// notifier queues transitions under the breaker lock and delivers them once
+// the lock is released. Whoever finds the queue idle drains it, anybody
+// arriving meanwhile, including a listener re-entering the breaker, only
+// enqueues.
+type notifier struct {
+	mu          sync.Mutex
+	listeners   []subscription
+	lastID      uint64
+	pending     []transition
+	dispatching bool
+}
+
+// subscribe returns the function that unsubscribes listener. A transition
+// already being delivered may still reach it.
+func (n *notifier) subscribe(listener StateChangeListener) func() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.lastID++
+	id := n.lastID
+	n.listeners = append(n.listeners, subscription{id: id, listen: listener})
+
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		// flush may still be delivering to the slice as it was, so it is
+		// replaced rather than changed in place.
+		n.listeners = slices.DeleteFunc(slices.Clone(n.listeners), func(s subscription) bool {
+			return s.id == id
+		})
+	}
+}
+
+func (n *notifier) emit(from, to State) {
+	asserts(from != to)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.listeners) == 0 {
+		return
+	}
+	n.pending = append(n.pending, transition{from: from, to: to})
+}
+
+// flush delivers the pending transitions unless somebody else already is.
+// dispatching is cleared under the same lock that finds the queue empty, so
+// a transition queued right after is never left for nobody to deliver.
+func (n *notifier) flush() {
+	n.mu.Lock()
+	if n.dispatching {
+		n.mu.Unlock()
+		return
+	}
+	n.dispatching = true
+
+	for len(n.pending) > 0 {
+		next := n.pending[0]
+		n.pending = n.pending[1:]
+		listeners := n.listeners
+		n.mu.Unlock()
+
+		n.deliver(listeners, next)
+
+		n.mu.Lock()
+	}
+	n.dispatching = false
+	n.mu.Unlock()
+}
+
+// deliver calls every listener with next. A panicking listener gives up the
+// dispatching before the panic goes on, the transitions left are delivered
+// by the next flush.
+func (n *notifier) deliver(listeners []subscription, next transition) {
+	completed := false
+	defer func() {
+		if !completed {
+			n.mu.Lock()
+			n.dispatching = false
+			n.mu.Unlock()
+		}
+	}()
+
+	for _, listener := range listeners {
+		listener.listen(next.from, next.to)
+	}
+	completed = true
+}
@@ -0,0 +1,244 @@
+package circuit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+type recorder struct {
+	mu          sync.Mutex
+	transitions []transition
+}
+
+func (r *recorder) listen(from, to State) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transitions = append(r.transitions, transition{from: from, to: to})
+}
+
+func (r *recorder) get() []transition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]transition(nil), r.transitions...)
+}
+
+func TestCountOnStateChange(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB(WithFailureThreshold(1), WithOpenRejections(1))
+	assert.NoError(t, err)
+	r := &recorder{}
+	c.OnStateChange(r.listen)
+
+	_ = c.Call(Error(t))
+	_ = c.Call(Ok(t))
+	_ = c.Call(Ok(t))
+	_ = c.Call(Ok(t))
+
+	assert.Equal(t, []transition{
+		{from: Closed, to: Open},
+		{from: Open, to: HalfOpen},
+		{from: HalfOpen, to: Closed},
+	}, r.get())
+}
+
+func TestTimeOnStateChangeHalfOpenFlip(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithHalfOpenFailureThreshold(1), WithFailureThreshold(1))
+	assert.NoError(t, err)
+	r := &recorder{}
+	cb.OnStateChange(r.listen)
+
+	_ = cb.Call(Error(t))
+	_ = cb.Call(Ok(t))
+	clock.Advance(2 * time.Millisecond)
+	// A single call flips Open -> HalfOpen -> Open.
+	_ = cb.Call(Error(t))
+
+	assert.Equal(t, []transition{
+		{from: Closed, to: Open},
+		{from: Open, to: HalfOpen},
+		{from: HalfOpen, to: Open},
+	}, r.get())
+}
+
+func TestRateOnStateChange(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithWindowSize(1), WithMinimumCalls(1), WithFailureRateThreshold(100))
+	assert.NoError(t, err)
+	r := &recorder{}
+	cb.OnStateChange(r.listen)
+
+	_ = cb.Call(Error(t))
+	clock.Advance(2 * time.Millisecond)
+	_ = cb.Call(Ok(t))
+
+	assert.Equal(t, []transition{
+		{from: Closed, to: Open},
+		{from: Open, to: HalfOpen},
+		{from: HalfOpen, to: Closed},
+	}, r.get())
+}
+
+func TestListenersRunInRegistrationOrder(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB(WithFailureThreshold(1), WithOpenRejections(1))
+	assert.NoError(t, err)
+
+	var order []int
+	c.OnStateChange(func(State, State) { order = append(order, 1) })
+	c.OnStateChange(func(State, State) { order = append(order, 2) })
+
+	_ = c.Call(Error(t))
+	assert.Equal(t, []int{1, 2}, order)
+}
+
+func TestCancelledListenerIsNotCalled(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB(WithFailureThreshold(1), WithOpenRejections(1))
+	assert.NoError(t, err)
+	cancelled := &recorder{}
+	kept := &recorder{}
+	cancel := c.OnStateChange(cancelled.listen)
+	c.OnStateChange(kept.listen)
+
+	_ = c.Call(Error(t))
+	cancel()
+	cancel()
+	_ = c.Call(Ok(t))
+
+	assert.Equal(t, []transition{{from: Closed, to: Open}}, cancelled.get())
+	assert.Equal(t, []transition{{from: Closed, to: Open}, {from: Open, to: HalfOpen}}, kept.get())
+}
+
+func TestListenerMayReenterBreaker(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB(WithFailureThreshold(1), WithOpenRejections(1))
+	assert.NoError(t, err)
+	r := &recorder{}
+
+	c.OnStateChange(func(from, to State) {
+		assert.Equal(t, to, c.State())
+		if to == Open {
+			// Triggers Open -> HalfOpen while Closed -> Open is delivered.
+			assert.Equal(t, Rejected, c.Call(Ok(t)))
+		}
+	})
+	c.OnStateChange(r.listen)
+
+	_ = c.Call(Error(t))
+
+	assert.Equal(t, []transition{
+		{from: Closed, to: Open},
+		{from: Open, to: HalfOpen},
+	}, r.get())
+	assert.Equal(t, HalfOpen, c.State())
+}
+
+func TestListenerPanicDoesNotWedgeNotifier(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB(WithFailureThreshold(1), WithOpenRejections(1))
+	assert.NoError(t, err)
+	r := &recorder{}
+
+	panicked := false
+	c.OnStateChange(func(State, State) {
+		if !panicked {
+			panicked = true
+			panic("listener")
+		}
+	})
+	c.OnStateChange(r.listen)
+
+	assert.Panics(t, func() {
+		_ = c.Call(Error(t))
+	})
+	_ = c.Call(Ok(t))
+
+	assert.Equal(t, []transition{{from: Open, to: HalfOpen}}, r.get())
+}
+
+func TestListenerSeesEveryTransitionOnceConcurrently(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("slow/integration: listener concurrent sim")
+	}
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(5*time.Millisecond), WithHalfOpenFailureThreshold(5), WithFailureThreshold(10))
+	assert.NoError(t, err)
+	r := &recorder{}
+	cb.OnStateChange(r.listen)
+
+	var wg sync.WaitGroup
+	for client := range 8 {
+		steps := generateRandomStepsTime(t, int64(client), 5_000)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, step := range steps {
+				switch step {
+				case TimeSuccess:
+					_ = cb.Call(Ok(t))
+				case TimeFailure:
+					_ = cb.Call(Error(t))
+				case TimeTick:
+					clock.Advance(time.Millisecond)
+				default:
+					panic("unreachable")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	transitions := r.get()
+	assert.NotEmpty(t, transitions)
+	from := Closed
+	for _, tr := range transitions {
+		assert.Equal(t, from, tr.from)
+		from = tr.to
+	}
+	assert.Equal(t, cb.State(), from)
+}
+
+// TestNotifierDeliversTransitionsOfRacingFlushes needs GOMAXPROCS > 1 for
+// the flushes to overlap.
+func TestNotifierDeliversTransitionsOfRacingFlushes(t *testing.T) {
+	t.Parallel()
+
+	var n notifier
+	var delivered atomic.Int64
+	n.subscribe(func(State, State) {
+		delivered.Add(1)
+	})
+
+	for i := range 100000 {
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				n.emit(Closed, Open)
+				n.flush()
+			}()
+		}
+		wg.Wait()
+		if !assert.Equal(t, int64(2*(i+1)), delivered.Load()) {
+			return
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package circuit
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var allStates = []State{Closed, Open, HalfOpen, ForcedOpen, ForcedClosed}
+
+type metricsEntry struct {
+	breaker     Breaker
+	cancel      func()
+	mu          sync.Mutex
+	transitions map[transition]uint64
+}
+
+func (e *metricsEntry) listen(from, to State) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.transitions[transition{from: from, to: to}]++
+}
+
+// MetricsHandler writes the state, call outcomes and transitions of the
+// registered breakers in the Prometheus text exposition format.
+type MetricsHandler struct {
+	mu      sync.Mutex
+	entries map[string]*metricsEntry
+}
+
+func NewMetricsHandler() *MetricsHandler {
+	return &MetricsHandler{entries: make(map[string]*metricsEntry)}
+}
+
+// Register exposes b under name. Transitions are counted from the moment the
+// breaker is registered.
+func (h *MetricsHandler) Register(name string, b Breaker) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.entries[name]; ok {
+		return fmt.Errorf("metrics: breaker %q already registered", name)
+	}
+
+	entry := &metricsEntry{breaker: b, transitions: make(map[transition]uint64)}
+	entry.cancel = b.OnStateChange(entry.listen)
+	h.entries[name] = entry
+	return nil
+}
+
+// Unregister stops exposing name and counting its transitions.
+func (h *MetricsHandler) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if entry, ok := h.entries[name]; ok {
+		entry.cancel()
+		delete(h.entries, name)
+	}
+}
+
+type metricsSample struct {
+	name        string
+	stats       Stats
+	transitions map[transition]uint64
+}
+
+// collect snapshots every breaker without holding h.mu, Stats may deliver
+// pending transitions to the listeners of this very handler.
+func (h *MetricsHandler) collect() []metricsSample {
+	h.mu.Lock()
+	names := make([]string, 0, len(h.entries))
+	entries := make(map[string]*metricsEntry, len(h.entries))
+	for name, entry := range h.entries {
+		names = append(names, name)
+		entries[name] = entry
+	}
+	h.mu.Unlock()
+	slices.Sort(names)
+
+	samples := make([]metricsSample, 0, len(names))
+	for _, name := range names {
+		entry := entries[name]
+		stats := entry.breaker.Stats()
+
+		entry.mu.Lock()
+		transitions := make(map[transition]uint64, len(entry.transitions))
+		for t, n := range entry.transitions {
+			transitions[t] = n
+		}
+		entry.mu.Unlock()
+
+		samples = append(samples, metricsSample{name: name, stats: stats, transitions: transitions})
+	}
+	return samples
+}
+
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	samples := h.collect()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintln(out, "# HELP circuit_breaker_state Current state of the breaker, 1 for the active state.")
+	fmt.Fprintln(out, "# TYPE circuit_breaker_state gauge")
+	for _, s := range samples {
+		for _, state := range allStates {
+			value := 0
+			if s.stats.State == state {
+				value = 1
+			}
+			fmt.Fprintf(out, "circuit_breaker_state{name=\"%s\",state=\"%s\"} %d\n", escapeLabel(s.name), stateLabel(state), value)
+		}
+	}
+
+	fmt.Fprintln(out, "# HELP circuit_breaker_calls_total Calls through the breaker by result.")
+	fmt.Fprintln(out, "# TYPE circuit_breaker_calls_total counter")
+	for _, s := range samples {
+		results := []struct {
+			result Result
+			count  uint64
+		}{
+			{Succeeded, s.stats.Successes},
+			{Failed, s.stats.Failures},
+			{Slow, s.stats.Slow},
+			{TimedOut, s.stats.TimedOut},
+			{Ignored, s.stats.Ignored},
+			{Rejected, s.stats.Rejections},
+			{TooManyProbes, s.stats.TooManyProbes},
+		}
+		for _, r := range results {
+			fmt.Fprintf(out, "circuit_breaker_calls_total{name=\"%s\",result=\"%s\"} %d\n", escapeLabel(s.name), r.result, r.count)
+		}
+	}
+
+	fmt.Fprintln(out, "# HELP circuit_breaker_overrides_total Calls to ForceOpen, ForceClosed and Reset.")
+	fmt.Fprintln(out, "# TYPE circuit_breaker_overrides_total counter")
+	for _, s := range samples {
+		fmt.Fprintf(out, "circuit_breaker_overrides_total{name=\"%s\"} %d\n", escapeLabel(s.name), s.stats.Overrides)
+	}
+
+	fmt.Fprintln(out, "# HELP circuit_breaker_transitions_total State transitions of the breaker.")
+	fmt.Fprintln(out, "# TYPE circuit_breaker_transitions_total counter")
+	for _, s := range samples {
+		for _, from := range allStates {
+			for _, to := range allStates {
+				if from == to {
+					continue
+				}
+				n := s.transitions[transition{from: from, to: to}]
+				fmt.Fprintf(out, "circuit_breaker_transitions_total{name=\"%s\",from=\"%s\",to=\"%s\"} %d\n", escapeLabel(s.name), stateLabel(from), stateLabel(to), n)
+			}
+		}
+	}
+
+	_ = out.Flush()
+}
+
+// stateLabel spells states in snake_case, like the results and most label
+// values, half_open rather than the half-open of State.String.
+func stateLabel(state State) string {
+	return strings.ReplaceAll(state.String(), "-", "_")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,166 @@
+package circuit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+var (
+	helpLine   = regexp.MustCompile(`^# HELP ([a-zA-Z_:][a-zA-Z0-9_:]*) .+$`)
+	typeLine   = regexp.MustCompile(`^# TYPE ([a-zA-Z_:][a-zA-Z0-9_:]*) (counter|gauge)$`)
+	sampleLine = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)\{([a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\\n]|\\[\\"n])*"(?:,[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\\n]|\\[\\"n])*")*)\} [0-9]+$`)
+)
+
+// scrape fetches the handler output and checks it against the text
+// exposition format: every sample belongs to the family of the last TYPE
+// line, HELP and TYPE appear once per family, before its samples.
+func scrape(t *testing.T, h http.Handler) string {
+	t.Helper()
+
+	req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	body := rec.Body.String()
+	assert.True(t, strings.HasSuffix(body, "\n"))
+
+	seen := map[string]bool{}
+	family := ""
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		switch {
+		case helpLine.MatchString(line):
+			name := helpLine.FindStringSubmatch(line)[1]
+			assert.False(t, seen[name], "family %s repeated", name)
+			seen[name] = true
+			family = ""
+		case typeLine.MatchString(line):
+			family = typeLine.FindStringSubmatch(line)[1]
+			assert.True(t, seen[family], "TYPE before HELP for %s", family)
+		case sampleLine.MatchString(line):
+			assert.Equal(t, family, sampleLine.FindStringSubmatch(line)[1], line)
+		default:
+			t.Errorf("invalid line %q", line)
+		}
+	}
+	return body
+}
+
+func TestMetricsEmpty(t *testing.T) {
+	t.Parallel()
+
+	body := scrape(t, NewMetricsHandler())
+	assert.Equal(t, strings.Join([]string{
+		"# HELP circuit_breaker_state Current state of the breaker, 1 for the active state.",
+		"# TYPE circuit_breaker_state gauge",
+		"# HELP circuit_breaker_calls_total Calls through the breaker by result.",
+		"# TYPE circuit_breaker_calls_total counter",
+		"# HELP circuit_breaker_overrides_total Calls to ForceOpen, ForceClosed and Reset.",
+		"# TYPE circuit_breaker_overrides_total counter",
+		"# HELP circuit_breaker_transitions_total State transitions of the breaker.",
+		"# TYPE circuit_breaker_transitions_total counter",
+		"",
+	}, "\n"), body)
+}
+
+func TestMetricsBreakers(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	timed, err := NewTimeCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithFailureThreshold(1))
+	assert.NoError(t, err)
+	count, err := NewCountCB()
+	assert.NoError(t, err)
+
+	h := NewMetricsHandler()
+	assert.NoError(t, h.Register("payments", timed))
+	assert.NoError(t, h.Register("users", count))
+	assert.ErrorContains(t, h.Register("users", count), "already registered")
+
+	_ = timed.Call(Error(t))
+	_ = timed.Call(Ok(t))
+	clock.Advance(2 * time.Millisecond)
+	_ = timed.Call(Error(t))
+	_ = count.Call(Ok(t))
+	count.ForceOpen()
+
+	body := scrape(t, h)
+	for _, line := range []string{
+		`circuit_breaker_state{name="payments",state="closed"} 0`,
+		`circuit_breaker_state{name="payments",state="open"} 1`,
+		`circuit_breaker_state{name="payments",state="half_open"} 0`,
+		`circuit_breaker_state{name="users",state="closed"} 0`,
+		`circuit_breaker_state{name="users",state="forced_open"} 1`,
+		`circuit_breaker_calls_total{name="payments",result="failed"} 2`,
+		`circuit_breaker_calls_total{name="payments",result="rejected"} 1`,
+		`circuit_breaker_calls_total{name="payments",result="succeeded"} 0`,
+		`circuit_breaker_calls_total{name="users",result="succeeded"} 1`,
+		`circuit_breaker_calls_total{name="users",result="too_many_probes"} 0`,
+		`circuit_breaker_transitions_total{name="payments",from="closed",to="open"} 1`,
+		`circuit_breaker_transitions_total{name="payments",from="open",to="half_open"} 1`,
+		`circuit_breaker_transitions_total{name="payments",from="half_open",to="open"} 1`,
+		`circuit_breaker_transitions_total{name="payments",from="half_open",to="closed"} 0`,
+		`circuit_breaker_transitions_total{name="users",from="closed",to="open"} 0`,
+		`circuit_breaker_transitions_total{name="users",from="closed",to="forced_open"} 1`,
+		`circuit_breaker_overrides_total{name="payments"} 0`,
+		`circuit_breaker_overrides_total{name="users"} 1`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+	assert.Less(t, strings.Index(body, `name="payments"`), strings.Index(body, `name="users"`))
+
+	h.Unregister("payments")
+	assert.NotContains(t, scrape(t, h), "payments")
+	h.Unregister("payments")
+}
+
+func TestMetricsUnregisterStopsCountingTransitions(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB(WithFailureThreshold(1))
+	assert.NoError(t, err)
+
+	h := NewMetricsHandler()
+	assert.NoError(t, h.Register("db", c))
+	entry := h.entries["db"]
+	h.Unregister("db")
+
+	_ = c.Call(Error(t))
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	assert.Empty(t, entry.transitions)
+}
+
+func TestMetricsEscapesLabels(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB()
+	assert.NoError(t, err)
+
+	h := NewMetricsHandler()
+	assert.NoError(t, h.Register("a\"b\\c\nd", c))
+
+	body := scrape(t, h)
+	assert.Contains(t, body, `circuit_breaker_state{name="a\"b\\c\nd",state="closed"} 1`)
+}
+
+func TestResultString(t *testing.T) {
+	t.Parallel()
+
+	names := make([]string, 0, 7)
+	for _, r := range []Result{Rejected, Failed, Succeeded, Slow, TimedOut, Ignored, TooManyProbes} {
+		names = append(names, r.String())
+	}
+	assert.Equal(t, []string{"rejected", "failed", "succeeded", "slow", "timed_out", "ignored", "too_many_probes"}, names)
+	assert.Equal(t, "Result(42)", Result(42).String())
+}
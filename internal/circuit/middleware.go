@@ -0,0 +1,72 @@
+package circuit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware runs every request of the wrapped handler through b. Handlers
+// that panic or respond with a 5xx status count as failures. Rejected
+// requests get a 503, with a Retry-After header when b tells how long it
+// stays open, as TimeCB and RateCB do. A breaker forced open stays open for
+// as long as its operator wants, so it gets none.
+func Middleware(b Breaker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w}
+			_, rejected := attempt(b, func() error {
+				next.ServeHTTP(recorder, r)
+				if recorder.status >= http.StatusInternalServerError {
+					return &classified{outcome: OutcomeFailure}
+				}
+				return &classified{outcome: OutcomeSuccess}
+			})
+			if rejected != nil {
+				reject(w, b, rejected)
+			}
+		})
+	}
+}
+
+func reject(w http.ResponseWriter, b Breaker, rejected *RejectedError) {
+	open, ok := b.(interface{ OpenRemaining() time.Duration })
+	if ok && rejected.State != ForcedOpen {
+		w.Header().Set("Retry-After", retryAfter(open.OpenRemaining()))
+	}
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
+// retryAfter rounds up to whole seconds, a half-open breaker that is out of
+// probes has nothing left to wait for but is still worth a second.
+func retryAfter(remaining time.Duration) string {
+	seconds := max(int64(math.Ceil(remaining.Seconds())), 1)
+	return strconv.FormatInt(seconds, 10)
+}
+
+// statusRecorder remembers the status code the handler responded with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 && status >= http.StatusOK {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the optional interfaces of the
+// underlying writer, such as http.Flusher.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
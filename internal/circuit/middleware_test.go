@@ -0,0 +1,192 @@
+package circuit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+func serve(h http.Handler) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/", nil))
+	return rec
+}
+
+func statusHandler(status int, calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		*calls++
+		w.WriteHeader(status)
+	})
+}
+
+func TestMiddlewareServerErrorOpensBreaker(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithFailureThreshold(1), WithOpenTimeout(10*time.Second))
+	assert.NoError(t, err)
+
+	calls := 0
+	h := Middleware(cb)(statusHandler(http.StatusInternalServerError, &calls))
+
+	assert.Equal(t, http.StatusInternalServerError, serve(h).Code)
+	assert.Equal(t, Open, cb.State())
+
+	rec := serve(h)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+
+	clock.Advance(2500 * time.Millisecond)
+	rec = serve(h)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "8", rec.Header().Get("Retry-After"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestMiddlewareClientErrorIsSuccess(t *testing.T) {
+	t.Parallel()
+
+	cb, err := NewCountCB(WithFailureThreshold(1))
+	assert.NoError(t, err)
+
+	calls := 0
+	h := Middleware(cb)(statusHandler(http.StatusNotFound, &calls))
+	for range 3 {
+		assert.Equal(t, http.StatusNotFound, serve(h).Code)
+	}
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, uint64(3), cb.Stats().Successes)
+
+	// Informational headers and superfluous WriteHeader calls do not count.
+	h = Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload")
+		w.WriteHeader(http.StatusEarlyHints)
+		_, _ = io.WriteString(w, "ok")
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	serve(h)
+	assert.Equal(t, Closed, cb.State())
+	assert.Equal(t, uint64(4), cb.Stats().Successes)
+}
+
+func TestMiddlewareOutsideBreaker(t *testing.T) {
+	t.Parallel()
+
+	b := &outsideBreaker{}
+	calls := 0
+	for range 3 {
+		assert.Equal(t, http.StatusOK, serve(Middleware(b)(statusHandler(http.StatusOK, &calls))).Code)
+	}
+	assert.Equal(t, 0, b.failures())
+
+	serve(Middleware(b)(statusHandler(http.StatusInternalServerError, &calls)))
+	assert.Equal(t, 1, b.failures())
+	assert.Equal(t, 4, calls)
+}
+
+func TestMiddlewarePanicIsFailure(t *testing.T) {
+	t.Parallel()
+
+	cb, err := NewCountCB(WithFailureThreshold(1))
+	assert.NoError(t, err)
+
+	h := Middleware(cb)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	assert.PanicsWithValue(t, "boom", func() {
+		serve(h)
+	})
+	assert.Equal(t, Open, cb.State())
+
+	// CountCB does not know how long it stays open.
+	rec := serve(h)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+}
+
+func TestMiddlewareTooManyProbes(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithFailureThreshold(1), WithOpenTimeout(time.Second))
+	assert.NoError(t, err)
+	_ = cb.Call(Error(t))
+	clock.Advance(2 * time.Second)
+
+	var inner *httptest.ResponseRecorder
+	h := Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		inner = serve(Middleware(cb)(http.NotFoundHandler()))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	assert.Equal(t, http.StatusNoContent, serve(h).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, inner.Code)
+	assert.Equal(t, "1", inner.Header().Get("Retry-After"))
+	assert.Equal(t, Closed, cb.State())
+}
+
+func TestMiddlewareUnwrapsResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	cb, err := NewCountCB()
+	assert.NoError(t, err)
+
+	h := Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		assert.NoError(t, http.NewResponseController(w).Flush())
+	}))
+	assert.True(t, serve(h).Flushed)
+}
+
+func TestMiddlewareForcedOpenOmitsRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	cb, err := NewTimeCB()
+	assert.NoError(t, err)
+	cb.ForceOpen()
+
+	calls := 0
+	rec := serve(Middleware(cb)(statusHandler(http.StatusOK, &calls)))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Empty(t, rec.Header().Values("Retry-After"))
+	assert.Equal(t, 0, calls)
+}
+
+func TestOpenRemaining(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	timeCB, err := NewTimeCB(WithClock(clock), WithFailureThreshold(1), WithOpenTimeout(5*time.Second))
+	assert.NoError(t, err)
+	rateCB, err := NewRateCB(WithClock(clock), WithWindowSize(1), WithMinimumCalls(1), WithOpenTimeout(5*time.Second))
+	assert.NoError(t, err)
+
+	for _, cb := range []interface {
+		Breaker
+		OpenRemaining() time.Duration
+	}{timeCB, rateCB} {
+		assert.Zero(t, cb.OpenRemaining())
+		_ = cb.Call(Error(t))
+		assert.Equal(t, 5*time.Second, cb.OpenRemaining())
+	}
+
+	clock.Advance(3 * time.Second)
+	assert.Equal(t, 2*time.Second, timeCB.OpenRemaining())
+	clock.Advance(3 * time.Second)
+	assert.Zero(t, timeCB.OpenRemaining())
+	assert.Zero(t, rateCB.OpenRemaining())
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "1", retryAfter(0))
+	assert.Equal(t, "1", retryAfter(time.Millisecond))
+	assert.Equal(t, "2", retryAfter(1001*time.Millisecond))
+	assert.Equal(t, "60", retryAfter(time.Minute))
+}
@@ -0,0 +1,368 @@
+package circuit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultFailureThreshold         = 5
+	DefaultOpenRejections           = 10
+	DefaultOpenTimeout              = 60 * time.Second
+	DefaultHalfOpenFailureThreshold = 1
+	DefaultMaxHalfOpenProbes        = 1
+	DefaultWindowSize               = 100
+	DefaultMinimumCalls             = 10
+	DefaultFailureRateThreshold     = 50
+	DefaultAllowTimeout             = 60 * time.Second
+	DefaultStoreRefresh             = time.Second
+)
+
+// FieldError describes one invalid configuration field.
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// ConfigError is returned by the constructors and lists every invalid field,
+// not only the first one found.
+type ConfigError struct {
+	Fields []*FieldError
+}
+
+func (e *ConfigError) Error() string {
+	reasons := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		reasons = append(reasons, field.Error())
+	}
+	return "circuit: invalid config: " + strings.Join(reasons, "; ")
+}
+
+func (e *ConfigError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		errs = append(errs, field)
+	}
+	return errs
+}
+
+type Option func(*options)
+
+// options holds the configuration of every breaker, each one only reads the
+// fields that apply to it.
+type options struct {
+	clock                    Clock
+	classifier               Classifier
+	failureThreshold         int
+	openRejections           int
+	openTimeout              time.Duration
+	halfOpenFailureThreshold int
+	maxHalfOpenProbes        int
+	windowSize               int
+	windowDuration           time.Duration
+	buckets                  int
+	minimumCalls             int
+	failureRateThreshold     int
+	slowCallThreshold        time.Duration
+	slowCallRateThreshold    int
+	maxOpenTimeout           time.Duration
+	jitter                   float64
+	random                   Random
+	store                    Store
+	storeRefresh             time.Duration
+	name                     string
+	scheduleHalfOpen         bool
+	allowTimeout             time.Duration
+}
+
+// constraint checks options that only make sense together, for the breakers
+// that read all of them.
+type constraint func(o *options, invalid func(field, format string, args ...any))
+
+func newOptions(opts []Option, constraints ...constraint) (options, error) {
+	o := options{
+		clock:                    &RealClock{},
+		classifier:               DefaultClassifier,
+		failureThreshold:         DefaultFailureThreshold,
+		openRejections:           DefaultOpenRejections,
+		openTimeout:              DefaultOpenTimeout,
+		halfOpenFailureThreshold: DefaultHalfOpenFailureThreshold,
+		maxHalfOpenProbes:        DefaultMaxHalfOpenProbes,
+		windowSize:               DefaultWindowSize,
+		minimumCalls:             DefaultMinimumCalls,
+		failureRateThreshold:     DefaultFailureRateThreshold,
+		allowTimeout:             DefaultAllowTimeout,
+		storeRefresh:             DefaultStoreRefresh,
+		random:                   globalRandom{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o, o.validate(constraints)
+}
+
+func (o *options) validate(constraints []constraint) error {
+	var fields []*FieldError
+	invalid := func(field, format string, args ...any) {
+		fields = append(fields, &FieldError{Field: field, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if o.clock == nil {
+		invalid("clock", "nil")
+	}
+	if o.classifier == nil {
+		invalid("classifier", "nil")
+	}
+	if o.failureThreshold <= 0 {
+		invalid("failureThreshold", "%d <= 0", o.failureThreshold)
+	}
+	if o.openRejections <= 0 {
+		invalid("openRejections", "%d <= 0", o.openRejections)
+	}
+	if o.openTimeout <= 0 {
+		invalid("openTimeout", "%s <= 0", o.openTimeout)
+	}
+	if o.halfOpenFailureThreshold <= 0 {
+		invalid("halfOpenFailureThreshold", "%d <= 0", o.halfOpenFailureThreshold)
+	}
+	if o.maxHalfOpenProbes <= 0 {
+		invalid("maxHalfOpenProbes", "%d <= 0", o.maxHalfOpenProbes)
+	}
+	if o.windowSize <= 0 {
+		invalid("windowSize", "%d <= 0", o.windowSize)
+	}
+	if o.windowDuration < 0 {
+		invalid("windowDuration", "%s < 0", o.windowDuration)
+	}
+	if o.windowDuration > 0 && o.buckets <= 0 {
+		invalid("buckets", "%d <= 0", o.buckets)
+	}
+	if o.windowDuration > 0 && o.buckets > 0 && o.windowDuration < time.Duration(o.buckets) {
+		invalid("windowDuration", "%s < %d buckets", o.windowDuration, o.buckets)
+	}
+	if o.minimumCalls <= 0 {
+		invalid("minimumCalls", "%d <= 0", o.minimumCalls)
+	}
+	if o.failureRateThreshold <= 0 || o.failureRateThreshold > 100 {
+		invalid("failureRateThreshold", "0 < %d <= 100", o.failureRateThreshold)
+	}
+	if o.slowCallThreshold < 0 {
+		invalid("slowCallThreshold", "%s < 0", o.slowCallThreshold)
+	}
+	if o.slowCallRateThreshold < 0 || o.slowCallRateThreshold > 100 {
+		invalid("slowCallRateThreshold", "0 <= %d <= 100", o.slowCallRateThreshold)
+	}
+	if o.maxOpenTimeout < 0 {
+		invalid("maxOpenTimeout", "%s < 0", o.maxOpenTimeout)
+	}
+	if o.jitter < 0 || o.jitter >= 1 {
+		invalid("jitter", "0 <= %v < 1", o.jitter)
+	}
+	if o.store != nil && o.name == "" {
+		invalid("name", "required by store")
+	}
+	if o.storeRefresh < 0 {
+		invalid("storeRefresh", "%s < 0", o.storeRefresh)
+	}
+	if o.allowTimeout <= 0 {
+		invalid("allowTimeout", "%s <= 0", o.allowTimeout)
+	}
+	for _, check := range constraints {
+		check(o, invalid)
+	}
+
+	if len(fields) > 0 {
+		return &ConfigError{Fields: fields}
+	}
+	return nil
+}
+
+// backoffConstraint applies to the breakers that stay open for openTimeout.
+func backoffConstraint(o *options, invalid func(field, format string, args ...any)) {
+	if o.maxOpenTimeout > 0 && o.maxOpenTimeout < o.openTimeout {
+		invalid("maxOpenTimeout", "%s < openTimeout %s", o.maxOpenTimeout, o.openTimeout)
+	}
+}
+
+// rateConstraint applies to RateCB, the only breaker with a window.
+func rateConstraint(o *options, invalid func(field, format string, args ...any)) {
+	if o.windowDuration == 0 && o.minimumCalls > o.windowSize {
+		invalid("minimumCalls", "%d > windowSize %d", o.minimumCalls, o.windowSize)
+	}
+	if o.slowCallRateThreshold > 0 && o.slowCallThreshold == 0 {
+		invalid("slowCallRateThreshold", "requires slowCallThreshold")
+	}
+}
+
+// WithClock sets the clock used to time calls and open timeouts, RealClock
+// by default.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithClassifier replaces DefaultClassifier. Calls classified as
+// OutcomeIgnore are reported as Ignored and leave the breaker untouched.
+func WithClassifier(classifier Classifier) Option {
+	return func(o *options) {
+		o.classifier = classifier
+	}
+}
+
+// WithFailureThreshold sets how many consecutive failures trip CountCB and
+// TimeCB.
+func WithFailureThreshold(n int) Option {
+	return func(o *options) {
+		o.failureThreshold = n
+	}
+}
+
+// WithOpenRejections sets how many calls CountCB rejects while open before
+// it moves to half-open.
+func WithOpenRejections(n int) Option {
+	return func(o *options) {
+		o.openRejections = n
+	}
+}
+
+// WithOpenTimeout sets how long TimeCB and RateCB stay open before letting
+// probes through.
+func WithOpenTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.openTimeout = d
+	}
+}
+
+// WithHalfOpenFailureThreshold sets how many probes may fail before TimeCB
+// opens again.
+func WithHalfOpenFailureThreshold(n int) Option {
+	return func(o *options) {
+		o.halfOpenFailureThreshold = n
+	}
+}
+
+// WithMaxHalfOpenProbes sets how many calls may be in flight while
+// half-open, every other caller is rejected with TooManyProbes.
+func WithMaxHalfOpenProbes(n int) Option {
+	return func(o *options) {
+		o.maxHalfOpenProbes = n
+	}
+}
+
+// WithWindowSize makes RateCB look at the outcomes of the last n calls.
+func WithWindowSize(n int) Option {
+	return func(o *options) {
+		o.windowSize = n
+		o.windowDuration = 0
+	}
+}
+
+// WithRollingWindow makes RateCB look at the outcomes of the last d of clock
+// time, split into buckets that expire as the clock advances.
+func WithRollingWindow(d time.Duration, buckets int) Option {
+	return func(o *options) {
+		o.windowDuration = d
+		o.buckets = buckets
+	}
+}
+
+// WithMinimumCalls sets how many outcomes RateCB needs in its window before
+// it may trip.
+func WithMinimumCalls(n int) Option {
+	return func(o *options) {
+		o.minimumCalls = n
+	}
+}
+
+// WithFailureRateThreshold sets the percentage of failures in the window that
+// trips RateCB.
+func WithFailureRateThreshold(percent int) Option {
+	return func(o *options) {
+		o.failureRateThreshold = percent
+	}
+}
+
+// WithSlowCallThreshold reports calls that succeed but take longer than d as
+// Slow. Zero, the default, disables slow call detection.
+func WithSlowCallThreshold(d time.Duration) Option {
+	return func(o *options) {
+		o.slowCallThreshold = d
+	}
+}
+
+// WithSlowCallRateThreshold makes RateCB track slow calls apart from
+// failures and trip once percent of the window is slow. Without it, and in
+// the consecutive failure breakers, a slow call counts as a failure.
+func WithSlowCallRateThreshold(percent int) Option {
+	return func(o *options) {
+		o.slowCallRateThreshold = percent
+	}
+}
+
+// WithBackoff doubles the open timeout every time the breaker trips again
+// without having closed in between, up to maxOpenTimeout. Closing resets it.
+func WithBackoff(maxOpenTimeout time.Duration) Option {
+	return func(o *options) {
+		o.maxOpenTimeout = maxOpenTimeout
+	}
+}
+
+// WithJitter shortens every open timeout by a random amount of up to fraction
+// of it, so breakers tripped together do not probe together. A nil random
+// uses the global math/rand/v2 source.
+func WithJitter(fraction float64, random Random) Option {
+	return func(o *options) {
+		o.jitter = fraction
+		if random != nil {
+			o.random = random
+		}
+	}
+}
+
+// WithStore shares the trips of CountCB and TimeCB with the breakers of the
+// same name through store, which requires WithName.
+func WithStore(store Store) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// WithStoreRefresh sets how long a breaker goes on with the trip it loaded
+// last before loading it from its Store again. Zero loads it on every call.
+func WithStoreRefresh(d time.Duration) Option {
+	return func(o *options) {
+		o.storeRefresh = d
+	}
+}
+
+// WithName names the breaker in its Store. Registry names every breaker it
+// creates after its key.
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithScheduledHalfOpen makes TimeCB move from open to half-open through a
+// timer of its clock as soon as the open period ends, instead of on the first
+// call after it, so State and the listeners are never behind. Close the
+// breaker to stop the timer once it is no longer used.
+func WithScheduledHalfOpen() Option {
+	return func(o *options) {
+		o.scheduleHalfOpen = true
+	}
+}
+
+// WithAllowTimeout sets how long a call reserved by Allow may take to report
+// its outcome before it is reported as TimedOut on its behalf.
+func WithAllowTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.allowTimeout = d
+	}
+}
@@ -0,0 +1,374 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+func mustOptions(t *testing.T, opts ...Option) options {
+	t.Helper()
+	o, err := newOptions(opts)
+	assert.NoError(t, err)
+	return o
+}
+
+func slowOk(clock *circuittest.Clock, d time.Duration) func() error {
+	return func() error {
+		clock.Advance(d)
+		return nil
+	}
+}
+
+func TestOptionsInvalid(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB(WithFailureThreshold(1), WithOpenRejections(1), WithClock(nil))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "clock")
+
+	c, err = NewCountCB(WithFailureThreshold(1), WithOpenRejections(1), WithSlowCallThreshold(-time.Second))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "slowCallThreshold")
+
+	c, err = NewCountCB(WithFailureThreshold(1), WithOpenRejections(1), WithSlowCallThreshold(time.Second), WithSlowCallRateThreshold(101))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "slowCallRateThreshold")
+
+	rc, err := NewRateCB(WithSlowCallRateThreshold(50))
+	assert.Nil(t, rc)
+	assert.ErrorContains(t, err, "slowCallRateThreshold")
+
+	tc, err := NewTimeCB(WithClock(circuittest.NewClock(time.Now())), WithOpenTimeout(time.Second), WithHalfOpenFailureThreshold(1), WithFailureThreshold(1), WithClock(nil))
+	assert.Nil(t, tc)
+	assert.ErrorContains(t, err, "clock")
+
+	rc, err = NewRateCB(WithClock(circuittest.NewClock(time.Now())), WithOpenTimeout(time.Second), WithWindowSize(1), WithMinimumCalls(1), WithFailureRateThreshold(50), WithClock(nil))
+	assert.Nil(t, rc)
+	assert.ErrorContains(t, err, "clock")
+
+	rc, err = NewRateCB(WithClock(circuittest.NewClock(time.Now())), WithOpenTimeout(time.Second), WithRollingWindow(time.Minute, 60), WithMinimumCalls(1), WithFailureRateThreshold(50), WithClock(nil))
+	assert.Nil(t, rc)
+	assert.ErrorContains(t, err, "clock")
+}
+
+func TestRunClassifiesOutcomes(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	o := mustOptions(t, WithClock(clock), WithSlowCallThreshold(time.Second))
+
+	assert.Equal(t, Succeeded, o.run(Ok(t)))
+	assert.Equal(t, Failed, o.run(Error(t)))
+	assert.Equal(t, Slow, o.run(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, TimedOut, o.run(func() error {
+		return fmt.Errorf("query: %w", context.DeadlineExceeded)
+	}))
+	assert.Equal(t, TimedOut, o.run(func() error {
+		return fmt.Errorf("read: %w", os.ErrDeadlineExceeded)
+	}))
+	assert.Equal(t, Failed, o.run(func() error {
+		clock.Advance(2 * time.Second)
+		return errors.New("slow and failed")
+	}))
+
+	disabled := mustOptions(t, WithClock(clock))
+	assert.Equal(t, Succeeded, disabled.run(slowOk(clock, 2*time.Second)))
+}
+
+func TestCountSlowCallsCountAsFailures(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	c, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(1), WithClock(clock), WithSlowCallThreshold(time.Second))
+	assert.NoError(t, err)
+
+	assert.Equal(t, Slow, c.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, Closed, c.State())
+
+	assert.Equal(t, Succeeded, c.Call(Ok(t)))
+	assert.Equal(t, Slow, c.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, Slow, c.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, Open, c.State())
+}
+
+func TestTimeSlowAndTimedOutCallsTrip(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(time.Second), WithHalfOpenFailureThreshold(1), WithFailureThreshold(2), WithSlowCallThreshold(time.Second))
+	assert.NoError(t, err)
+
+	assert.Equal(t, Slow, cb.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, Closed, cb.State())
+
+	assert.Equal(t, TimedOut, cb.Call(func() error {
+		return context.DeadlineExceeded
+	}))
+	assert.Equal(t, Open, cb.State())
+
+	clock.Advance(2 * time.Second)
+
+	assert.Equal(t, Slow, cb.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, Open, cb.State())
+}
+
+func TestRateSlowCallRateTripsSeparately(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithWindowSize(4), WithMinimumCalls(4), WithFailureRateThreshold(50), WithSlowCallThreshold(time.Second), WithSlowCallRateThreshold(75))
+	assert.NoError(t, err)
+
+	// Slow calls do not feed the failure rate, 2 slow out of 4 stays closed.
+	assert.Equal(t, Slow, cb.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, Slow, cb.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	assert.Equal(t, Closed, cb.State())
+
+	assert.Equal(t, Slow, cb.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, Slow, cb.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, Closed, cb.State())
+
+	assert.Equal(t, Slow, cb.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, Open, cb.State())
+}
+
+func TestRateSlowCallsCountAsFailuresWithoutRate(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithWindowSize(2), WithMinimumCalls(2), WithFailureRateThreshold(100), WithSlowCallThreshold(time.Second))
+	assert.NoError(t, err)
+
+	assert.Equal(t, Slow, cb.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, TimedOut, cb.Call(func() error {
+		return context.DeadlineExceeded
+	}))
+	assert.Equal(t, Open, cb.State())
+
+	clock.Advance(2 * time.Second)
+
+	assert.Equal(t, Slow, cb.Call(slowOk(clock, 2*time.Second)))
+	assert.Equal(t, Open, cb.State())
+}
+
+func ignoreCanceled(err error) Outcome {
+	if errors.Is(err, context.Canceled) {
+		return OutcomeIgnore
+	}
+	return DefaultClassifier(err)
+}
+
+func TestDefaultClassifier(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, OutcomeSuccess, DefaultClassifier(nil))
+	assert.Equal(t, OutcomeFailure, DefaultClassifier(errors.New("error")))
+	assert.Equal(t, OutcomeFailure, DefaultClassifier(context.Canceled))
+}
+
+func TestRunUsesClassifier(t *testing.T) {
+	t.Parallel()
+
+	errValidation := errors.New("validation")
+	classifier := func(err error) Outcome {
+		switch {
+		case errors.Is(err, errValidation):
+			return OutcomeSuccess
+		case err == nil:
+			return OutcomeFailure
+		default:
+			return ignoreCanceled(err)
+		}
+	}
+	clock := circuittest.NewClock(time.Now())
+	o := mustOptions(t, WithClock(clock), WithClassifier(classifier))
+
+	assert.Equal(t, Succeeded, o.run(func() error { return errValidation }))
+	assert.Equal(t, Ignored, o.run(func() error { return context.Canceled }))
+	assert.Equal(t, Failed, o.run(Ok(t)))
+	assert.Equal(t, Failed, o.run(Error(t)))
+
+	invalid := mustOptions(t, WithClock(clock), WithClassifier(func(error) Outcome { return Outcome(42) }))
+	assert.Panics(t, func() {
+		_ = invalid.run(Ok(t))
+	})
+
+	c, err := NewCountCB(WithFailureThreshold(1), WithOpenRejections(1), WithClassifier(nil))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "classifier")
+}
+
+func TestIgnoredOutcomesLeaveBreakersUntouched(t *testing.T) {
+	t.Parallel()
+
+	canceled := func() error {
+		return context.Canceled
+	}
+
+	count, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(1), WithClassifier(ignoreCanceled))
+	assert.NoError(t, err)
+	timed, err := NewTimeCB(WithClock(circuittest.NewClock(time.Now())), WithOpenTimeout(time.Millisecond), WithHalfOpenFailureThreshold(1), WithFailureThreshold(2), WithClassifier(ignoreCanceled))
+	assert.NoError(t, err)
+	rate, err := NewRateCB(WithClock(circuittest.NewClock(time.Now())), WithOpenTimeout(time.Millisecond), WithWindowSize(2), WithMinimumCalls(2), WithFailureRateThreshold(100), WithClassifier(ignoreCanceled))
+	assert.NoError(t, err)
+
+	for name, b := range map[string]Breaker{"count": count, "time": timed, "rate": rate} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, Failed, b.Call(Error(t)))
+			for range 10 {
+				assert.Equal(t, Ignored, b.Call(canceled))
+			}
+			assert.Equal(t, Closed, b.State())
+
+			assert.Equal(t, Failed, b.Call(Error(t)))
+			assert.Equal(t, Open, b.State())
+		})
+	}
+}
+
+func TestIgnoredProbeKeepsHalfOpen(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB(WithFailureThreshold(1), WithOpenRejections(1), WithClassifier(ignoreCanceled))
+	assert.NoError(t, err)
+
+	assert.Equal(t, Failed, c.Call(Error(t)))
+	assert.Equal(t, Rejected, c.Call(Ok(t)))
+	assert.Equal(t, HalfOpen, c.State())
+
+	assert.Equal(t, Ignored, c.Call(func() error { return context.Canceled }))
+	assert.Equal(t, HalfOpen, c.State())
+
+	assert.Equal(t, Succeeded, c.Call(Ok(t)))
+	assert.Equal(t, Closed, c.State())
+}
+
+func TestDefaults(t *testing.T) {
+	t.Parallel()
+
+	o := mustOptions(t)
+	assert.IsType(t, &RealClock{}, o.clock)
+	assert.Equal(t, DefaultFailureThreshold, o.failureThreshold)
+	assert.Equal(t, DefaultOpenRejections, o.openRejections)
+	assert.Equal(t, DefaultOpenTimeout, o.openTimeout)
+	assert.Equal(t, DefaultHalfOpenFailureThreshold, o.halfOpenFailureThreshold)
+	assert.Equal(t, DefaultWindowSize, o.windowSize)
+	assert.Equal(t, DefaultMinimumCalls, o.minimumCalls)
+	assert.Equal(t, DefaultFailureRateThreshold, o.failureRateThreshold)
+	assert.Equal(t, DefaultStoreRefresh, o.storeRefresh)
+
+	count, err := NewCountCB()
+	assert.NoError(t, err)
+	assert.Equal(t, Closed, count.State())
+
+	timed, err := NewTimeCB()
+	assert.NoError(t, err)
+	assert.Equal(t, Closed, timed.State())
+
+	rate, err := NewRateCB()
+	assert.NoError(t, err)
+	assert.Equal(t, Closed, rate.State())
+}
+
+func TestConfigErrorListsEveryField(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewTimeCB(
+		WithFailureThreshold(0),
+		WithOpenTimeout(-time.Second),
+		WithHalfOpenFailureThreshold(-1),
+		WithJitter(2, nil),
+	)
+	assert.Nil(t, c)
+
+	var config *ConfigError
+	assert.ErrorAs(t, err, &config)
+
+	fields := make([]string, 0, len(config.Fields))
+	for _, field := range config.Fields {
+		fields = append(fields, field.Field)
+	}
+	assert.Equal(t, []string{"failureThreshold", "openTimeout", "halfOpenFailureThreshold", "jitter"}, fields)
+	assert.EqualError(t, err, "circuit: invalid config: failureThreshold: 0 <= 0; openTimeout: -1s <= 0; halfOpenFailureThreshold: -1 <= 0; jitter: 0 <= 2 < 1")
+
+	var field *FieldError
+	assert.ErrorAs(t, err, &field)
+	assert.Equal(t, "failureThreshold", field.Field)
+}
+
+func TestConstraintsOnlyApplyToBreakersUsingThem(t *testing.T) {
+	t.Parallel()
+
+	opts := []Option{WithMinimumCalls(200), WithSlowCallRateThreshold(50), WithOpenTimeout(time.Minute), WithBackoff(time.Second)}
+	_, err := NewCountCB(opts...)
+	assert.NoError(t, err)
+
+	_, err = NewTimeCB(opts...)
+	var config *ConfigError
+	assert.ErrorAs(t, err, &config)
+	assert.Len(t, config.Fields, 1)
+	assert.Equal(t, "maxOpenTimeout", config.Fields[0].Field)
+
+	_, err = NewRateCB(opts...)
+	assert.EqualError(t, err, "circuit: invalid config: maxOpenTimeout: 1s < openTimeout 1m0s; minimumCalls: 200 > windowSize 100; slowCallRateThreshold: requires slowCallThreshold")
+}
+
+func TestConfigErrorAllFields(t *testing.T) {
+	t.Parallel()
+
+	_, err := newOptions([]Option{
+		WithClock(nil),
+		WithClassifier(nil),
+		WithFailureThreshold(0),
+		WithOpenRejections(0),
+		WithOpenTimeout(time.Minute),
+		WithHalfOpenFailureThreshold(0),
+		WithWindowSize(0),
+		WithRollingWindow(-time.Second, 0),
+		WithMinimumCalls(0),
+		WithFailureRateThreshold(0),
+		WithSlowCallThreshold(-time.Second),
+		WithSlowCallRateThreshold(101),
+		WithBackoff(time.Second),
+		WithJitter(1, nil),
+		WithStoreRefresh(-time.Second),
+		WithAllowTimeout(0),
+	}, backoffConstraint, rateConstraint)
+
+	var config *ConfigError
+	assert.ErrorAs(t, err, &config)
+	fields := make([]string, 0, len(config.Fields))
+	for _, field := range config.Fields {
+		fields = append(fields, field.Field)
+	}
+	assert.Equal(t, []string{
+		"clock", "classifier", "failureThreshold", "openRejections", "halfOpenFailureThreshold",
+		"windowSize", "windowDuration", "minimumCalls", "failureRateThreshold",
+		"slowCallThreshold", "slowCallRateThreshold", "jitter", "storeRefresh", "allowTimeout",
+		"maxOpenTimeout",
+	}, fields)
+}
+
+func TestRunBypassesClassifierForClassified(t *testing.T) {
+	t.Parallel()
+
+	o := mustOptions(t, WithClassifier(func(error) Outcome { return OutcomeFailure }))
+
+	assert.Equal(t, Succeeded, o.run(func() error { return &classified{outcome: OutcomeSuccess} }))
+	assert.Equal(t, Ignored, o.run(func() error { return &classified{outcome: OutcomeIgnore} }))
+	assert.Equal(t, TimedOut, o.run(func() error {
+		return &classified{outcome: OutcomeFailure, err: context.DeadlineExceeded}
+	}))
+	assert.EqualError(t, &classified{outcome: OutcomeFailure, err: io.EOF}, "circuit: classified 1: EOF")
+}
@@ -0,0 +1,183 @@
+package circuit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+func TestForceOpen(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			rec := &recorder{}
+			b.OnStateChange(rec.listen)
+
+			b.ForceOpen()
+			assert.Equal(t, ForcedOpen, b.State())
+			for range 5 {
+				assert.Equal(t, Rejected, b.Call(Ok(t)))
+			}
+
+			var rejected *RejectedError
+			assert.ErrorAs(t, b.Execute(Ok(t)), &rejected)
+			assert.ErrorIs(t, rejected, ErrOpen)
+			assert.Equal(t, ForcedOpen, rejected.State)
+
+			b.Reset()
+			assert.Equal(t, Closed, b.State())
+			assert.Equal(t, Succeeded, b.Call(Ok(t)))
+
+			stats := b.Stats()
+			assert.Equal(t, uint64(6), stats.Rejections)
+			assert.Equal(t, uint64(2), stats.Overrides)
+			assert.Equal(t, []transition{{from: Closed, to: ForcedOpen}, {from: ForcedOpen, to: Closed}}, rec.get())
+		})
+	}
+}
+
+func TestForceClosed(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, Failed, b.Call(Error(t)))
+			assert.Equal(t, Open, b.State())
+
+			b.ForceClosed()
+			for range 5 {
+				assert.Equal(t, Failed, b.Call(Error(t)))
+			}
+			assert.Equal(t, ForcedClosed, b.State())
+
+			stats := b.Stats()
+			assert.Equal(t, ForcedClosed, stats.State)
+			assert.Equal(t, uint64(6), stats.Failures)
+			assert.Equal(t, uint64(1), stats.Overrides)
+
+			// Clearing the override goes back to counting failures.
+			b.Reset()
+			assert.Equal(t, Failed, b.Call(Error(t)))
+			assert.Equal(t, Open, b.State())
+		})
+	}
+}
+
+func TestOverrideIgnoresCallsInFlight(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, Failed, b.Call(func() error {
+				b.ForceClosed()
+				return assert.AnError
+			}))
+			assert.Equal(t, ForcedClosed, b.State())
+
+			assert.Equal(t, Succeeded, b.Call(func() error {
+				b.Reset()
+				b.ForceOpen()
+				return nil
+			}))
+			assert.Equal(t, ForcedOpen, b.State())
+		})
+	}
+}
+
+func TestResetClearsFailures(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	timed, err := NewTimeCB(WithClock(clock), WithFailureThreshold(2), WithOpenTimeout(time.Second), WithBackoff(time.Minute))
+	assert.NoError(t, err)
+	rec := &recorder{}
+	timed.OnStateChange(rec.listen)
+
+	// Resetting a closed breaker clears its failures without a transition.
+	_ = timed.Call(Error(t))
+	timed.Reset()
+	_ = timed.Call(Error(t))
+	assert.Equal(t, Closed, timed.State())
+	assert.Empty(t, rec.get())
+
+	// Resetting an open breaker clears its backoff.
+	_ = timed.Call(Error(t))
+	clock.Advance(2 * time.Second)
+	_ = timed.Call(Error(t))
+	assert.Equal(t, 2*time.Second, timed.OpenRemaining())
+	timed.Reset()
+	_ = timed.Call(Error(t))
+	_ = timed.Call(Error(t))
+	assert.Equal(t, time.Second, timed.OpenRemaining())
+	assert.Equal(t, uint64(2), timed.Stats().Overrides)
+}
+
+func TestOverrideSnapshot(t *testing.T) {
+	t.Parallel()
+
+	count, err := NewCountCB()
+	assert.NoError(t, err)
+	count.ForceOpen()
+	timed, err := NewTimeCB()
+	assert.NoError(t, err)
+	timed.ForceClosed()
+
+	data, err := json.Marshal(count)
+	assert.NoError(t, err)
+	restoredCount, err := NewCountCB()
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, restoredCount))
+	assert.Equal(t, ForcedOpen, restoredCount.State())
+	assert.Equal(t, uint64(1), restoredCount.Stats().Overrides)
+
+	data, err = json.Marshal(timed)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"state":"forced-closed"`)
+	restoredTimed, err := NewTimeCB()
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, restoredTimed))
+	assert.Equal(t, ForcedClosed, restoredTimed.State())
+}
+
+func TestForcedOpenRejectionsTellOverridesApart(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			b.ForceOpen()
+
+			var rejected *RejectedError
+			_, result, err := Do(b, func() (int, error) {
+				return 42, nil
+			})
+			assert.Equal(t, Rejected, result)
+			assert.ErrorAs(t, err, &rejected)
+			assert.Equal(t, ForcedOpen, rejected.State)
+
+			err = b.CallWithFallback(Ok(t), func(reason FallbackReason, err error) error {
+				assert.Equal(t, FallbackRejected, reason)
+				return err
+			})
+			assert.ErrorAs(t, err, &rejected)
+			assert.Equal(t, ForcedOpen, rejected.State)
+
+			// Breakers of other packages only report a result, which does
+			// not tell overrides apart.
+			_, _, err = Do(struct{ Breaker }{b}, func() (int, error) {
+				return 42, nil
+			})
+			assert.ErrorAs(t, err, &rejected)
+			assert.Equal(t, Open, rejected.State)
+		})
+	}
+}
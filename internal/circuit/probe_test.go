@@ -0,0 +1,135 @@
+package circuit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+// newHalfOpenBreakers returns newBreakers already half-open, with the probe
+// that got them there ignored so its slot is free again.
+func newHalfOpenBreakers(t *testing.T, opts ...Option) map[string]Breaker {
+	t.Helper()
+
+	clock := circuittest.NewClock(time.Now())
+	breakers := newBreakers(t, clock, append([]Option{WithClassifier(ignoreCanceled), WithHalfOpenFailureThreshold(2)}, opts...)...)
+	for name, b := range breakers {
+		assert.Equal(t, Failed, b.Call(Error(t)), name)
+	}
+	clock.Advance(time.Millisecond)
+	for name, b := range breakers {
+		_ = b.Call(func() error { return context.Canceled })
+		assert.Equal(t, HalfOpen, b.State(), name)
+	}
+	return breakers
+}
+
+func TestHalfOpenRejectsProbesOverLimit(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newHalfOpenBreakers(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			called := false
+			result := b.Call(func() error {
+				assert.Equal(t, TooManyProbes, b.Call(func() error {
+					called = true
+					return nil
+				}))
+				assert.Equal(t, HalfOpen, b.State())
+				return nil
+			})
+			assert.False(t, called)
+			assert.Equal(t, Succeeded, result)
+			assert.Equal(t, Closed, b.State())
+		})
+	}
+}
+
+func TestHalfOpenAllowsConfiguredProbes(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newHalfOpenBreakers(t, WithMaxHalfOpenProbes(3)) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			canceled := func() error { return context.Canceled }
+			result := b.Call(func() error {
+				assert.Equal(t, Ignored, b.Call(func() error {
+					assert.Equal(t, Ignored, b.Call(func() error {
+						assert.Equal(t, TooManyProbes, b.Call(Ok(t)))
+						return context.Canceled
+					}))
+					return context.Canceled
+				}))
+				assert.Equal(t, Ignored, b.Call(canceled))
+				return nil
+			})
+			assert.Equal(t, Succeeded, result)
+			assert.Equal(t, Closed, b.State())
+		})
+	}
+}
+
+func TestHalfOpenProbeSlotReleased(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newHalfOpenBreakers(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Ignored outcomes and panics both give the slot back.
+			assert.Equal(t, Ignored, b.Call(func() error { return context.Canceled }))
+			assert.Equal(t, HalfOpen, b.State())
+
+			assert.Panics(t, func() {
+				_ = b.Call(func() error {
+					panic("probe")
+				})
+			})
+			assert.NotEqual(t, TooManyProbes, b.Call(Ok(t)))
+		})
+	}
+}
+
+func TestPanicCountsAsFailure(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewCountCB(WithFailureThreshold(1))
+	assert.NoError(t, err)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = c.Call(func() error {
+			panic("boom")
+		})
+	})
+	assert.Equal(t, Open, c.State())
+}
+
+func TestDoRejectsTooManyProbes(t *testing.T) {
+	t.Parallel()
+
+	b := newHalfOpenBreakers(t)["time"]
+	result := b.Call(func() error {
+		_, result, err := Do(b, func() (int, error) {
+			return 42, nil
+		})
+		assert.Equal(t, TooManyProbes, result)
+
+		var rejected *RejectedError
+		assert.ErrorAs(t, err, &rejected)
+		assert.Equal(t, HalfOpen, rejected.State)
+
+		_, result, err = Do(struct{ Breaker }{b}, func() (int, error) {
+			return 42, nil
+		})
+		assert.Equal(t, TooManyProbes, result)
+		assert.ErrorIs(t, err, ErrTooManyProbes)
+		return nil
+	})
+	assert.Equal(t, Succeeded, result)
+}
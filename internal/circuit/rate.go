@@ -0,0 +1,196 @@
+package circuit
+
+import (
+	"context"
+	"time"
+)
+
+type RateCB struct {
+	core
+	clock                Clock
+	openFor              time.Duration
+	trips                int
+	openAt               *time.Time
+	window               window
+	minimumCalls         int
+	failureRateThreshold int
+}
+
+// NewRateCB looks at the failure rate over the last WithWindowSize calls, or
+// over the last WithRollingWindow of clock time.
+func NewRateCB(opts ...Option) (*RateCB, error) {
+	o, err := newOptions(opts, backoffConstraint, rateConstraint)
+	if err != nil {
+		return nil, err
+	}
+
+	var w window = newCountWindow(o.windowSize)
+	if o.windowDuration > 0 {
+		w = newTimeWindow(o.windowDuration, o.buckets)
+	}
+
+	c := &RateCB{
+		core:                 core{opts: o, state: Closed},
+		clock:                o.clock,
+		openAt:               nil,
+		window:               w,
+		minimumCalls:         o.minimumCalls,
+		failureRateThreshold: o.failureRateThreshold,
+	}
+	c.forget = func() {
+		c.window.reset()
+		c.openAt = nil
+		c.openFor = 0
+		c.trips = 0
+	}
+	return c, nil
+}
+
+func (c *RateCB) Call(f func() error) Result {
+	result, _ := call(c, f)
+	return result
+}
+
+func (c *RateCB) CallContext(ctx context.Context, f func(context.Context) error) Result {
+	return callContext(ctx, c, f)
+}
+
+func (c *RateCB) Execute(f func() error) error {
+	return execute(c, f)
+}
+
+func (c *RateCB) ExecuteContext(ctx context.Context, f func(context.Context) error) error {
+	return executeContext(ctx, c, f)
+}
+
+func (c *RateCB) CallWithFallback(f func() error, fallback Fallback) error {
+	return callWithFallback(c, f, fallback)
+}
+
+func (c *RateCB) Allow() (func(err error), bool) {
+	return allow(c)
+}
+
+func (c *RateCB) before() (uint64, *RejectedError) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	switch c.state {
+	case Closed:
+		asserts(c.openAt == nil)
+		c.counters.admit()
+		return c.generation, nil
+	case Open:
+		asserts(c.openAt != nil)
+
+		if c.clock.Now().After(c.openAt.Add(c.openFor)) {
+			c.setState(HalfOpen)
+			asserts(c.probe())
+			c.counters.admit()
+			return c.generation, nil
+		}
+		return c.generation, c.reject(Rejected)
+	case HalfOpen:
+		asserts(c.openAt != nil)
+
+		if !c.probe() {
+			return c.generation, c.reject(TooManyProbes)
+		}
+		c.counters.admit()
+		return c.generation, nil
+	case ForcedOpen:
+		return c.generation, c.reject(Rejected)
+	case ForcedClosed:
+		c.counters.admit()
+		return c.generation, nil
+	default:
+		panic("unreachable")
+	}
+}
+
+func (c *RateCB) after(generation uint64, result Result) Result {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.counters.complete(result, c.failed(result) || result == Slow)
+	if !c.settle(generation) || result == Ignored {
+		return result
+	}
+
+	switch c.state {
+	case Closed:
+		asserts(c.openAt == nil)
+
+		c.window.record(c.clock.Now(), c.failed(result), result == Slow)
+		if c.tripped() {
+			c.open()
+		}
+		return result
+	case HalfOpen:
+		asserts(c.openAt != nil)
+
+		if c.failed(result) || result == Slow {
+			c.open()
+			return result
+		}
+		c.setState(Closed)
+		c.openAt = nil
+		c.openFor = 0
+		c.trips = 0
+		return result
+	case ForcedClosed:
+		return result
+	default:
+		panic("unreachable")
+	}
+}
+
+// failed reports whether result counts against the failure rate. Slow calls
+// only do when there is no separate slow call rate to account them in.
+func (c *RateCB) failed(result Result) bool {
+	switch result {
+	case Failed, TimedOut:
+		return true
+	case Slow:
+		return c.opts.slowCallRateThreshold == 0
+	default:
+		return false
+	}
+}
+
+// tripped reports whether the failure or slow call rate of the window reached
+// its threshold. Nothing trips until the window holds minimumCalls outcomes.
+func (c *RateCB) tripped() bool {
+	total, failures, slow := c.window.counts(c.clock.Now())
+	if total < c.minimumCalls {
+		return false
+	}
+
+	if failures*100 >= c.failureRateThreshold*total {
+		return true
+	}
+
+	threshold := c.opts.slowCallRateThreshold
+	return threshold > 0 && slow*100 >= threshold*total
+}
+
+func (c *RateCB) open() {
+	c.setState(Open)
+	c.window.reset()
+	c.openFor = c.opts.backoff(c.trips)
+	c.trips++
+	now := c.clock.Now()
+	c.openAt = &now
+}
+
+// OpenRemaining is how long the breaker stays open before it lets a probe
+// through, zero when it is not open.
+func (c *RateCB) OpenRemaining() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != Open {
+		return 0
+	}
+	return max(c.openAt.Add(c.openFor).Sub(c.clock.Now()), 0)
+}
@@ -0,0 +1,217 @@
+package circuit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+func TestNewRateCBInvalid(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+
+	c, err := NewRateCB(WithClock(clock), WithOpenTimeout(0), WithWindowSize(10), WithMinimumCalls(5), WithFailureRateThreshold(50))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "openTimeout")
+
+	c, err = NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithWindowSize(0), WithMinimumCalls(5), WithFailureRateThreshold(50))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "windowSize")
+
+	c, err = NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithWindowSize(10), WithMinimumCalls(0), WithFailureRateThreshold(50))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "minimumCalls")
+
+	c, err = NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithWindowSize(10), WithMinimumCalls(11), WithFailureRateThreshold(50))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "minimumCalls")
+
+	c, err = NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithWindowSize(10), WithMinimumCalls(5), WithFailureRateThreshold(0))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "failureRateThreshold")
+
+	c, err = NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithWindowSize(10), WithMinimumCalls(5), WithFailureRateThreshold(101))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "failureRateThreshold")
+}
+
+func TestRateTripsOnFailureRateDespiteSuccesses(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithWindowSize(10), WithMinimumCalls(5), WithFailureRateThreshold(50))
+	assert.NoError(t, err)
+
+	// Alternating outcomes never trip a consecutive failure breaker.
+	steps := []func() error{Error(t), Ok(t), Error(t), Ok(t)}
+	for _, step := range steps {
+		_ = cb.Call(step)
+		assert.Equal(t, Closed, cb.State())
+	}
+
+	result := cb.Call(Error(t))
+	assert.Equal(t, Failed, result)
+	assert.Equal(t, Open, cb.State())
+
+	result = cb.Call(Ok(t))
+	assert.Equal(t, Rejected, result)
+	assert.Equal(t, Open, cb.State())
+}
+
+func TestRateWaitsForMinimumCalls(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithWindowSize(10), WithMinimumCalls(4), WithFailureRateThreshold(50))
+	assert.NoError(t, err)
+
+	for range 3 {
+		assert.Equal(t, Failed, cb.Call(Error(t)))
+		assert.Equal(t, Closed, cb.State())
+	}
+
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	assert.Equal(t, Open, cb.State())
+}
+
+func TestRateBelowThresholdStaysClosed(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithWindowSize(4), WithMinimumCalls(4), WithFailureRateThreshold(60))
+	assert.NoError(t, err)
+
+	// The window only ever holds the last 4 outcomes, at most 2 failures.
+	for range 10 {
+		_ = cb.Call(Error(t))
+		_ = cb.Call(Ok(t))
+		_ = cb.Call(Ok(t))
+		assert.Equal(t, Closed, cb.State())
+	}
+}
+
+func TestRateHalfOpenSuccessCloses(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithWindowSize(2), WithMinimumCalls(2), WithFailureRateThreshold(100))
+	assert.NoError(t, err)
+
+	_ = cb.Call(Error(t))
+	_ = cb.Call(Error(t))
+	assert.Equal(t, Open, cb.State())
+
+	clock.Advance(2 * time.Millisecond)
+
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	assert.Equal(t, Closed, cb.State())
+
+	// The window was reset, a single failure is below minimumCalls.
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	assert.Equal(t, Closed, cb.State())
+}
+
+func TestRateHalfOpenFailureReopens(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithWindowSize(2), WithMinimumCalls(2), WithFailureRateThreshold(100))
+	assert.NoError(t, err)
+
+	_ = cb.Call(Error(t))
+	_ = cb.Call(Error(t))
+	assert.Equal(t, Open, cb.State())
+
+	clock.Advance(2 * time.Millisecond)
+
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	assert.Equal(t, Open, cb.State())
+	assert.Equal(t, Rejected, cb.Call(Ok(t)))
+}
+
+func TestRateHalfOpenAdmitsConcurrentProbes(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithWindowSize(1), WithMinimumCalls(1), WithFailureRateThreshold(100), WithMaxHalfOpenProbes(2))
+	assert.NoError(t, err)
+
+	_ = cb.Call(Error(t))
+	clock.Advance(2 * time.Millisecond)
+
+	result := cb.Call(func() error {
+		assert.Equal(t, HalfOpen, cb.State())
+		assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+		assert.Equal(t, Closed, cb.State())
+		return nil
+	})
+	assert.Equal(t, Succeeded, result)
+	assert.Equal(t, Closed, cb.State())
+}
+
+func TestNewRollingRateCBInvalid(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+
+	c, err := NewRateCB(WithClock(clock), WithOpenTimeout(0), WithRollingWindow(time.Minute, 60), WithMinimumCalls(5), WithFailureRateThreshold(50))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "openTimeout")
+
+	c, err = NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithRollingWindow(time.Minute, 0), WithMinimumCalls(5), WithFailureRateThreshold(50))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "buckets")
+
+	c, err = NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithRollingWindow(10*time.Nanosecond, 60), WithMinimumCalls(5), WithFailureRateThreshold(50))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "windowDuration")
+
+	c, err = NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithRollingWindow(time.Minute, 60), WithMinimumCalls(0), WithFailureRateThreshold(50))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "minimumCalls")
+
+	c, err = NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithRollingWindow(time.Minute, 60), WithMinimumCalls(5), WithFailureRateThreshold(101))
+	assert.Nil(t, c)
+	assert.ErrorContains(t, err, "failureRateThreshold")
+}
+
+func TestRollingRateTripsWithinWindow(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithRollingWindow(time.Minute, 60), WithMinimumCalls(4), WithFailureRateThreshold(50))
+	assert.NoError(t, err)
+
+	for _, step := range []func() error{Ok(t), Ok(t), Error(t)} {
+		_ = cb.Call(step)
+		assert.Equal(t, Closed, cb.State())
+		clock.Advance(time.Second)
+	}
+
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	assert.Equal(t, Open, cb.State())
+}
+
+func TestRollingRateForgetsExpiredBuckets(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(time.Second), WithRollingWindow(10*time.Second, 10), WithMinimumCalls(4), WithFailureRateThreshold(50))
+	assert.NoError(t, err)
+
+	for range 3 {
+		assert.Equal(t, Failed, cb.Call(Error(t)))
+	}
+	assert.Equal(t, Closed, cb.State())
+
+	// No calls while the failures age out of the window.
+	for range 10 {
+		clock.Advance(time.Second)
+	}
+
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	assert.Equal(t, Closed, cb.State())
+}
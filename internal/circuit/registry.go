@@ -0,0 +1,126 @@
+package circuit
+
+import (
+	"io"
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Registry lazily creates one breaker per name, all from the same template
+// options. It is safe for concurrent use.
+type Registry[B Breaker] struct {
+	mu         sync.RWMutex
+	newBreaker func(opts ...Option) (B, error)
+	template   []Option
+	breakers   map[string]B
+}
+
+// NewRegistry takes one of the breaker constructors, such as NewTimeCB, and
+// the options every breaker is created with. The options are validated once
+// here, named as Get names them, so Get never fails on a bad template.
+func NewRegistry[B Breaker](newBreaker func(opts ...Option) (B, error), template ...Option) (*Registry[B], error) {
+	b, err := newBreaker(append(slices.Clone(template), WithName("template"))...)
+	if err != nil {
+		return nil, err
+	}
+	closeBreaker(b)
+
+	return &Registry[B]{
+		newBreaker: newBreaker,
+		template:   slices.Clone(template),
+		breakers:   make(map[string]B),
+	}, nil
+}
+
+// Get returns the breaker for name, creating it on first use with the
+// template and WithName(name). It panics where Breaker fails, use Breaker for
+// names that come from callers, such as request hosts.
+func (r *Registry[B]) Get(name string) B {
+	b, err := r.Breaker(name)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Breaker is Get for names that may be invalid. It fails on an empty name
+// when the template has a Store, which needs the name to share trips under.
+func (r *Registry[B]) Breaker(name string) (B, error) {
+	if b, ok := r.Lookup(name); ok {
+		return b, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if b, ok := r.breakers[name]; ok {
+		return b, nil
+	}
+
+	b, err := r.newBreaker(append(slices.Clone(r.template), WithName(name))...)
+	if err != nil {
+		var zero B
+		return zero, err
+	}
+	r.breakers[name] = b
+	return b, nil
+}
+
+// Lookup returns the breaker for name without creating it.
+func (r *Registry[B]) Lookup(name string) (B, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b, ok := r.breakers[name]
+	return b, ok
+}
+
+// Remove forgets the breaker for name, the next Get creates a fresh one. The
+// breaker is closed if it is an io.Closer, such as TimeCB.
+func (r *Registry[B]) Remove(name string) bool {
+	r.mu.Lock()
+	b, ok := r.breakers[name]
+	delete(r.breakers, name)
+	r.mu.Unlock()
+
+	if ok {
+		closeBreaker(b)
+	}
+	return ok
+}
+
+func closeBreaker(b Breaker) {
+	if closer, ok := b.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+func (r *Registry[B]) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.breakers)
+}
+
+// Names returns the names of every breaker, sorted.
+func (r *Registry[B]) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Sorted(maps.Keys(r.breakers))
+}
+
+// All iterates a snapshot of the breakers sorted by name. The registry is not
+// locked while yielding, so the loop body may call Get or Remove.
+func (r *Registry[B]) All() iter.Seq2[string, B] {
+	return func(yield func(string, B) bool) {
+		r.mu.RLock()
+		snapshot := maps.Clone(r.breakers)
+		r.mu.RUnlock()
+
+		for _, name := range slices.Sorted(maps.Keys(snapshot)) {
+			if !yield(name, snapshot[name]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package circuit
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+func TestNewRegistryValidatesTemplate(t *testing.T) {
+	t.Parallel()
+
+	r, err := NewRegistry(NewTimeCB, WithFailureThreshold(0))
+	assert.Nil(t, r)
+
+	var config *ConfigError
+	assert.ErrorAs(t, err, &config)
+}
+
+func TestRegistryCreatesLazilyFromTemplate(t *testing.T) {
+	t.Parallel()
+
+	r, err := NewRegistry(NewCountCB, WithFailureThreshold(1))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, r.Len())
+
+	_, ok := r.Lookup("db")
+	assert.False(t, ok)
+
+	db := r.Get("db")
+	assert.Same(t, db, r.Get("db"))
+	assert.Equal(t, 1, r.Len())
+
+	// Created with the template, a single failure trips it.
+	assert.Equal(t, Failed, db.Call(Error(t)))
+	assert.Equal(t, Open, db.State())
+	assert.Equal(t, Closed, r.Get("cache").State())
+
+	found, ok := r.Lookup("db")
+	assert.True(t, ok)
+	assert.Same(t, db, found)
+}
+
+func TestRegistryRemove(t *testing.T) {
+	t.Parallel()
+
+	r, err := NewRegistry(NewCountCB, WithFailureThreshold(1))
+	assert.NoError(t, err)
+
+	db := r.Get("db")
+	_ = db.Call(Error(t))
+
+	assert.True(t, r.Remove("db"))
+	assert.False(t, r.Remove("db"))
+	assert.Equal(t, 0, r.Len())
+
+	fresh := r.Get("db")
+	assert.NotSame(t, db, fresh)
+	assert.Equal(t, Closed, fresh.State())
+}
+
+func TestRegistryRemoveClosesBreaker(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	r, err := NewRegistry(NewTimeCB, WithClock(clock), WithFailureThreshold(1), WithScheduledHalfOpen())
+	assert.NoError(t, err)
+
+	_ = r.Get("db").Call(Error(t))
+	assert.Equal(t, 1, clock.Timers())
+
+	assert.True(t, r.Remove("db"))
+	assert.Zero(t, clock.Timers())
+}
+
+func TestRegistryListAndIterate(t *testing.T) {
+	t.Parallel()
+
+	r, err := NewRegistry(NewRateCB)
+	assert.NoError(t, err)
+
+	for _, name := range []string{"users", "db", "payments"} {
+		_ = r.Get(name)
+	}
+	assert.Equal(t, []string{"db", "payments", "users"}, r.Names())
+
+	var names []string
+	for name, b := range r.All() {
+		assert.Same(t, r.Get(name), b)
+		names = append(names, name)
+		// The registry is not locked while iterating.
+		r.Remove(name)
+	}
+	assert.Equal(t, []string{"db", "payments", "users"}, names)
+	assert.Equal(t, 0, r.Len())
+
+	_ = r.Get("a")
+	_ = r.Get("b")
+	for name := range r.All() {
+		assert.Equal(t, "a", name)
+		break
+	}
+}
+
+func TestRegistryWithBreakerInterface(t *testing.T) {
+	t.Parallel()
+
+	newBreaker := func(opts ...Option) (Breaker, error) {
+		return NewTimeCB(opts...)
+	}
+	r, err := NewRegistry(newBreaker)
+	assert.NoError(t, err)
+	assert.IsType(t, &TimeCB{}, r.Get("db"))
+}
+
+func TestRegistryConcurrentGet(t *testing.T) {
+	t.Parallel()
+
+	r, err := NewRegistry(NewTimeCB)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	got := make([][]*TimeCB, 8)
+	for client := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				got[client] = append(got[client], r.Get(fmt.Sprintf("host-%d", i%10)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, r.Len())
+	for _, breakers := range got {
+		for i, b := range breakers {
+			assert.Same(t, got[0][i], b)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package circuit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+func newScheduledTimeCB(t *testing.T, opts ...Option) (*TimeCB, *circuittest.Clock) {
+	t.Helper()
+	clock := circuittest.NewClock(time.Now())
+	opts = append([]Option{WithClock(clock), WithFailureThreshold(1), WithOpenTimeout(time.Second), WithScheduledHalfOpen()}, opts...)
+	cb, err := NewTimeCB(opts...)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, cb.Close())
+	})
+	return cb, clock
+}
+
+func TestScheduledHalfOpen(t *testing.T) {
+	t.Parallel()
+
+	cb, clock := newScheduledTimeCB(t, WithBackoff(time.Minute))
+	rec := &recorder{}
+	cb.OnStateChange(rec.listen)
+
+	_ = cb.Call(Error(t))
+	assert.Equal(t, 1, clock.Timers())
+
+	// Like for calls, the open period ends strictly after the open timeout.
+	clock.Advance(time.Second)
+	assert.Equal(t, Open, cb.State())
+	clock.Advance(time.Nanosecond)
+	assert.Equal(t, HalfOpen, cb.State())
+	assert.Equal(t, []transition{{from: Closed, to: Open}, {from: Open, to: HalfOpen}}, rec.get())
+	assert.Zero(t, clock.Timers())
+
+	// A failed probe schedules the next, longer, open period.
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	clock.Advance(time.Second + time.Nanosecond)
+	assert.Equal(t, Open, cb.State())
+	clock.Advance(time.Second)
+	assert.Equal(t, HalfOpen, cb.State())
+
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	assert.Equal(t, Closed, cb.State())
+	assert.Zero(t, clock.Timers())
+}
+
+func TestScheduledHalfOpenStopsOnStateChange(t *testing.T) {
+	t.Parallel()
+
+	cb, clock := newScheduledTimeCB(t)
+	rec := &recorder{}
+	cb.OnStateChange(rec.listen)
+
+	_ = cb.Call(Error(t))
+	cb.ForceOpen()
+	assert.Zero(t, clock.Timers())
+	cb.Reset()
+
+	_ = cb.Call(Error(t))
+	clock.Advance(time.Hour)
+	assert.Equal(t, []transition{
+		{from: Closed, to: Open},
+		{from: Open, to: ForcedOpen},
+		{from: ForcedOpen, to: Closed},
+		{from: Closed, to: Open},
+		{from: Open, to: HalfOpen},
+	}, rec.get())
+}
+
+// racingClock hands out timers that can no longer be stopped, as if they had
+// fired already and were waiting for the breaker lock.
+type racingClock struct {
+	*circuittest.Clock
+	timers []func()
+}
+
+func (c *racingClock) AfterFunc(_ time.Duration, f func()) func() bool {
+	c.timers = append(c.timers, f)
+	return func() bool { return false }
+}
+
+func TestScheduledHalfOpenIgnoresStaleTimer(t *testing.T) {
+	t.Parallel()
+
+	clock := &racingClock{Clock: circuittest.NewClock(time.Now())}
+	cb, err := NewTimeCB(WithClock(clock), WithFailureThreshold(1), WithScheduledHalfOpen())
+	assert.NoError(t, err)
+
+	_ = cb.Call(Error(t))
+	cb.Reset()
+	assert.Len(t, clock.timers, 1)
+
+	clock.timers[0]()
+	assert.Equal(t, Closed, cb.State())
+}
+
+func TestScheduledHalfOpenClose(t *testing.T) {
+	t.Parallel()
+
+	cb, clock := newScheduledTimeCB(t)
+	_ = cb.Call(Error(t))
+	assert.Equal(t, 1, clock.Timers())
+
+	assert.NoError(t, cb.Close())
+	assert.Zero(t, clock.Timers())
+	clock.Advance(time.Hour)
+	assert.Equal(t, Open, cb.State())
+
+	// A closed breaker still leaves the open state on calls.
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	assert.Equal(t, Closed, cb.State())
+	_ = cb.Call(Error(t))
+	assert.Zero(t, clock.Timers())
+}
+
+func TestScheduledHalfOpenAdoptsAndRestores(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	replica1, clock := newScheduledTimeCB(t, WithStore(store), WithName("db"))
+	replica2, err := NewTimeCB(WithClock(clock), WithFailureThreshold(1), WithOpenTimeout(time.Second), WithScheduledHalfOpen(), WithStore(store), WithName("db"))
+	assert.NoError(t, err)
+	defer replica2.Close()
+
+	_ = replica1.Call(Error(t))
+	assert.Equal(t, Rejected, replica2.Call(Ok(t)))
+	data, err := json.Marshal(replica1)
+	assert.NoError(t, err)
+
+	restored, err := NewTimeCB(WithClock(clock), WithFailureThreshold(1), WithOpenTimeout(time.Second), WithScheduledHalfOpen())
+	assert.NoError(t, err)
+	defer restored.Close()
+	assert.NoError(t, json.Unmarshal(data, restored))
+	assert.Equal(t, 3, clock.Timers())
+
+	// Restoring again replaces the timer.
+	assert.NoError(t, json.Unmarshal(data, restored))
+	assert.Equal(t, 3, clock.Timers())
+
+	clock.Advance(2 * time.Second)
+	for _, cb := range []*TimeCB{replica1, replica2, restored} {
+		assert.Equal(t, HalfOpen, cb.State())
+	}
+}
+
+func TestScheduledHalfOpenRealClock(t *testing.T) {
+	t.Parallel()
+
+	cb, err := NewTimeCB(WithFailureThreshold(1), WithOpenTimeout(time.Millisecond), WithScheduledHalfOpen())
+	assert.NoError(t, err)
+	defer cb.Close()
+
+	_ = cb.Call(Error(t))
+	assert.Eventually(t, func() bool {
+		return cb.State() == HalfOpen
+	}, time.Second, time.Millisecond)
+}
@@ -2,10 +2,12 @@ package circuit
 
 import (
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
 )
 
 type StepCount int
@@ -73,7 +75,7 @@ func TestCountCBRandomeSequence(t *testing.T) {
 	count := 100_000
 	steps := generateRandomStepsCount(t, seed, count)
 
-	cb, err := NewCountCB(uint8(failureThreshold), uint8(halfOpenThreshold))
+	cb, err := NewCountCB(WithFailureThreshold(failureThreshold), WithOpenRejections(halfOpenThreshold))
 	assert.NotNil(t, cb)
 	assert.NoError(t, err)
 
@@ -106,9 +108,9 @@ func TestTimeCBRandomeSequence(t *testing.T) {
 	count := 100_000
 	steps := generateRandomStepsTime(t, seed, count)
 	start := time.Now()
-	clock := NewTestClock(start, 1*time.Millisecond)
+	clock := circuittest.NewClock(start)
 
-	cb, err := NewTimeCB(clock, openTimeout, uint8(halfOpenProbesThreshold), uint8(closedFailuresThreshold))
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(openTimeout), WithHalfOpenFailureThreshold(halfOpenProbesThreshold), WithFailureThreshold(closedFailuresThreshold))
 	assert.NotNil(t, cb)
 	assert.NoError(t, err)
 
@@ -123,9 +125,126 @@ func TestTimeCBRandomeSequence(t *testing.T) {
 				_ = cb.Call(Error(t))
 			})
 		case TimeTick:
-			clock.Tick()
+			clock.Advance(time.Millisecond)
 		default:
 			panic("unreachable")
 		}
 	}
 }
+
+func TestCountCBConcurrentClients(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("slow/integration: count concurrent sim")
+	}
+
+	clients := 8
+	count := 10_000
+	cb, err := NewCountCB(WithFailureThreshold(10), WithOpenRejections(4))
+	assert.NotNil(t, cb)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for client := range clients {
+		steps := generateRandomStepsCount(t, int64(client), count)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, step := range steps {
+				switch step {
+				case CountSuccess:
+					assert.NotPanics(t, func() {
+						_ = cb.Call(Ok(t))
+					})
+				case CountFailure:
+					assert.NotPanics(t, func() {
+						_ = cb.Call(Error(t))
+					})
+				default:
+					panic("unreachable")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTimeCBConcurrentClients(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("slow/integration: time concurrent sim")
+	}
+
+	clients := 8
+	count := 10_000
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(5*time.Millisecond), WithHalfOpenFailureThreshold(5), WithFailureThreshold(10))
+	assert.NotNil(t, cb)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for client := range clients {
+		steps := generateRandomStepsTime(t, int64(client), count)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, step := range steps {
+				switch step {
+				case TimeSuccess:
+					assert.NotPanics(t, func() {
+						_ = cb.Call(Ok(t))
+					})
+				case TimeFailure:
+					assert.NotPanics(t, func() {
+						_ = cb.Call(Error(t))
+					})
+				case TimeTick:
+					clock.Advance(time.Millisecond)
+				default:
+					panic("unreachable")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRateCBConcurrentClients(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("slow/integration: rate concurrent sim")
+	}
+
+	clients := 8
+	count := 10_000
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(WithClock(clock), WithOpenTimeout(5*time.Millisecond), WithWindowSize(20), WithMinimumCalls(10), WithFailureRateThreshold(60))
+	assert.NotNil(t, cb)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for client := range clients {
+		steps := generateRandomStepsTime(t, int64(client), count)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, step := range steps {
+				switch step {
+				case TimeSuccess:
+					assert.NotPanics(t, func() {
+						_ = cb.Call(Ok(t))
+					})
+				case TimeFailure:
+					assert.NotPanics(t, func() {
+						_ = cb.Call(Error(t))
+					})
+				case TimeTick:
+					clock.Advance(time.Millisecond)
+				default:
+					panic("unreachable")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
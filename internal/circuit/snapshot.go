@@ -0,0 +1,187 @@
+package circuit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidSnapshot is returned when a snapshot breaks the invariants of the
+// breaker it is restored into, for instance because it was taken from a
+// breaker with other thresholds.
+var ErrInvalidSnapshot = errors.New("circuit: invalid snapshot")
+
+func invalidSnapshot(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidSnapshot, fmt.Sprintf(format, args...))
+}
+
+type countSnapshot struct {
+	State            State `json:"state"`
+	ClosedFailures   int   `json:"closedFailures"`
+	HalfOpenAttempts int   `json:"halfOpenAttempts"`
+	Stats            Stats `json:"stats"`
+}
+
+// MarshalJSON snapshots the state and counters of the breaker, not its
+// options.
+func (c *CountCB) MarshalJSON() ([]byte, error) {
+	c.mu.Lock()
+	snapshot := countSnapshot{
+		State:            c.state,
+		ClosedFailures:   c.closedFailures,
+		HalfOpenAttempts: c.halfOpenAttempts,
+		Stats:            c.counters.snapshot(c.state),
+	}
+	c.mu.Unlock()
+	return json.Marshal(snapshot)
+}
+
+// UnmarshalJSON restores a snapshot taken by MarshalJSON into a breaker built
+// by NewCountCB. The breaker is left untouched when the snapshot does not fit
+// its thresholds. Calls in flight while restoring do not affect the restored
+// state.
+func (c *CountCB) UnmarshalJSON(data []byte) error {
+	var snapshot countSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	if err := snapshot.validate(c.closedFailuresThreshold, c.halfOpenThreshold); err != nil {
+		return err
+	}
+
+	c.restoreState(snapshot.State)
+	c.closedFailures = snapshot.ClosedFailures
+	c.halfOpenAttempts = snapshot.HalfOpenAttempts
+	c.counters.restore(snapshot.Stats)
+	return nil
+}
+
+// validate mirrors the asserts of CountCB.
+func (s *countSnapshot) validate(failureThreshold, openRejections int) error {
+	if err := s.Stats.validate(s.State); err != nil {
+		return err
+	}
+
+	switch s.State {
+	case Closed, ForcedOpen, ForcedClosed:
+		if s.ClosedFailures < 0 || s.ClosedFailures >= failureThreshold {
+			return invalidSnapshot("%d failures in %s state, threshold is %d", s.ClosedFailures, s.State, failureThreshold)
+		}
+		if s.HalfOpenAttempts != 0 {
+			return invalidSnapshot("%d open rejections in %s state", s.HalfOpenAttempts, s.State)
+		}
+	case Open, HalfOpen:
+		if s.ClosedFailures != failureThreshold {
+			return invalidSnapshot("%d failures in %s state, threshold is %d", s.ClosedFailures, s.State, failureThreshold)
+		}
+		if s.HalfOpenAttempts < 0 || s.HalfOpenAttempts >= openRejections {
+			return invalidSnapshot("%d open rejections in %s state, limit is %d", s.HalfOpenAttempts, s.State, openRejections)
+		}
+	default:
+		panic("unreachable")
+	}
+	return nil
+}
+
+type timeSnapshot struct {
+	State            State         `json:"state"`
+	ClosedFailures   int           `json:"closedFailures"`
+	HalfOpenFailures int           `json:"halfOpenFailures"`
+	Trips            int           `json:"trips"`
+	OpenAt           *time.Time    `json:"openAt,omitempty"`
+	OpenFor          time.Duration `json:"openFor"`
+	Stats            Stats         `json:"stats"`
+}
+
+// MarshalJSON snapshots the state and counters of the breaker, not its
+// options. OpenAt is absolute, a breaker restored later has less time left
+// to stay open.
+func (c *TimeCB) MarshalJSON() ([]byte, error) {
+	c.mu.Lock()
+	snapshot := timeSnapshot{
+		State:            c.state,
+		ClosedFailures:   c.closedFailures,
+		HalfOpenFailures: c.halfOpenProbes,
+		Trips:            c.trips,
+		OpenFor:          c.openFor,
+		Stats:            c.counters.snapshot(c.state),
+	}
+	if c.openAt != nil {
+		openAt := *c.openAt
+		snapshot.OpenAt = &openAt
+	}
+	c.mu.Unlock()
+	return json.Marshal(snapshot)
+}
+
+// UnmarshalJSON restores a snapshot taken by MarshalJSON into a breaker built
+// by NewTimeCB, see CountCB.UnmarshalJSON.
+func (c *TimeCB) UnmarshalJSON(data []byte) error {
+	var snapshot timeSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.clock == nil {
+		return invalidSnapshot("breaker was not built by NewTimeCB")
+	}
+	err := snapshot.validate(c.closedFailuresThreshold, c.halfOpenProbesThreshold)
+	if err != nil {
+		return err
+	}
+
+	c.restoreState(snapshot.State)
+	c.closedFailures = snapshot.ClosedFailures
+	c.halfOpenProbes = snapshot.HalfOpenFailures
+	c.trips = snapshot.Trips
+	c.openAt = snapshot.OpenAt
+	c.openFor = snapshot.OpenFor
+	c.counters.restore(snapshot.Stats)
+	c.stop()
+	if c.state == Open {
+		c.schedule()
+	}
+	return nil
+}
+
+// validate mirrors the asserts of TimeCB. A half-open snapshot may be taken
+// before its open period ends by the clock, which can go back.
+func (s *timeSnapshot) validate(failureThreshold, halfOpenFailureThreshold int) error {
+	if err := s.Stats.validate(s.State); err != nil {
+		return err
+	}
+
+	switch s.State {
+	case Closed, ForcedOpen, ForcedClosed:
+		if s.ClosedFailures < 0 || s.ClosedFailures >= failureThreshold {
+			return invalidSnapshot("%d failures in %s state, threshold is %d", s.ClosedFailures, s.State, failureThreshold)
+		}
+		if s.HalfOpenFailures != 0 || s.OpenAt != nil || s.Trips != 0 || s.OpenFor != 0 {
+			return invalidSnapshot("open period in %s state", s.State)
+		}
+	case Open, HalfOpen:
+		if s.ClosedFailures != failureThreshold {
+			return invalidSnapshot("%d failures in %s state, threshold is %d", s.ClosedFailures, s.State, failureThreshold)
+		}
+		if s.OpenAt == nil || s.Trips <= 0 || s.OpenFor <= 0 {
+			return invalidSnapshot("no open period in %s state", s.State)
+		}
+		if s.State == Open && s.HalfOpenFailures != 0 {
+			return invalidSnapshot("%d half-open failures in open state", s.HalfOpenFailures)
+		}
+		if s.HalfOpenFailures < 0 || s.HalfOpenFailures >= halfOpenFailureThreshold {
+			return invalidSnapshot("%d half-open failures, threshold is %d", s.HalfOpenFailures, halfOpenFailureThreshold)
+		}
+	default:
+		panic("unreachable")
+	}
+	return nil
+}
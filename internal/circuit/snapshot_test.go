@@ -0,0 +1,251 @@
+package circuit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+// assertStats compares LastTransition by instant, restored times have no
+// monotonic reading.
+func assertStats(t *testing.T, expected, actual Stats) {
+	t.Helper()
+	assert.True(t, expected.LastTransition.Equal(actual.LastTransition))
+	expected.LastTransition, actual.LastTransition = time.Time{}, time.Time{}
+	assert.Equal(t, expected, actual)
+}
+
+func TestCountCBSnapshotRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	opts := []Option{WithFailureThreshold(2), WithOpenRejections(3)}
+	cb, err := NewCountCB(opts...)
+	assert.NoError(t, err)
+	_ = cb.Call(Ok(t))
+	_ = cb.Call(Error(t))
+	_ = cb.Call(Error(t))
+	assert.Equal(t, Rejected, cb.Call(Ok(t)))
+
+	data, err := json.Marshal(cb)
+	assert.NoError(t, err)
+
+	restored, err := NewCountCB(opts...)
+	assert.NoError(t, err)
+	rec := &recorder{}
+	restored.OnStateChange(rec.listen)
+	assert.NoError(t, json.Unmarshal(data, restored))
+
+	assertStats(t, cb.Stats(), restored.Stats())
+	assert.Equal(t, []transition{{from: Closed, to: Open}}, rec.get())
+
+	// One rejection was spent before the snapshot, two are left.
+	assert.Equal(t, Rejected, restored.Call(Ok(t)))
+	assert.Equal(t, Rejected, restored.Call(Ok(t)))
+	assert.Equal(t, HalfOpen, restored.State())
+}
+
+func TestTimeCBSnapshotRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	opts := []Option{WithClock(clock), WithFailureThreshold(1), WithOpenTimeout(10 * time.Second), WithBackoff(time.Minute)}
+	cb, err := NewTimeCB(opts...)
+	assert.NoError(t, err)
+	_ = cb.Call(Error(t))
+	clock.Advance(4 * time.Second)
+
+	data, err := json.Marshal(cb)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"state":"open"`)
+	assert.Contains(t, string(data), `"openAt":`)
+
+	restored, err := NewTimeCB(opts...)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, restored))
+	assert.Equal(t, Open, restored.State())
+	assert.Equal(t, 6*time.Second, restored.OpenRemaining())
+
+	clock.Advance(4 * time.Second)
+	clock.Advance(4 * time.Second)
+	assert.Equal(t, Failed, restored.Call(Error(t)))
+
+	// The restored trip count keeps backing off.
+	assert.Equal(t, 20*time.Second, restored.OpenRemaining())
+}
+
+func TestTimeCBSnapshotHalfOpenClockGoesBack(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	clock := circuittest.NewClock(start)
+	opts := []Option{WithClock(clock), WithFailureThreshold(1), WithOpenTimeout(time.Second), WithHalfOpenFailureThreshold(2)}
+	cb, err := NewTimeCB(opts...)
+	assert.NoError(t, err)
+	_ = cb.Call(Error(t))
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	assert.Equal(t, HalfOpen, cb.State())
+
+	clock.Set(start)
+	data, err := json.Marshal(cb)
+	assert.NoError(t, err)
+	restored, err := NewTimeCB(opts...)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, restored))
+	assert.Equal(t, HalfOpen, restored.State())
+	assert.Equal(t, Succeeded, restored.Call(Ok(t)))
+	assert.Equal(t, Closed, restored.State())
+}
+
+func TestSnapshotClosedRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, newBreaker := range []func() (json.Marshaler, Breaker){
+		func() (json.Marshaler, Breaker) {
+			cb, _ := NewCountCB(WithFailureThreshold(3))
+			return cb, cb
+		},
+		func() (json.Marshaler, Breaker) {
+			cb, _ := NewTimeCB(WithFailureThreshold(3))
+			return cb, cb
+		},
+	} {
+		marshaler, cb := newBreaker()
+		_ = cb.Call(Error(t))
+		_ = cb.Call(Error(t))
+		data, err := marshaler.MarshalJSON()
+		assert.NoError(t, err)
+
+		unmarshaler, restored := newBreaker()
+		assert.NoError(t, unmarshaler.(json.Unmarshaler).UnmarshalJSON(data))
+		assertStats(t, cb.Stats(), restored.Stats())
+
+		// Two failures were restored, the third trips it.
+		_ = restored.Call(Error(t))
+		assert.Equal(t, Open, restored.State())
+	}
+}
+
+func TestSnapshotDropsCallsInFlight(t *testing.T) {
+	t.Parallel()
+
+	cb, err := NewCountCB(WithFailureThreshold(1))
+	assert.NoError(t, err)
+
+	var data []byte
+	_ = cb.Call(func() error {
+		data, err = json.Marshal(cb)
+		return err
+	})
+	assert.Contains(t, string(data), `"requests":1`)
+
+	restored, err := NewCountCB(WithFailureThreshold(1))
+	assert.NoError(t, err)
+	assert.Equal(t, Failed, restored.Call(func() error {
+		assert.NoError(t, json.Unmarshal(data, restored))
+		return assert.AnError
+	}))
+
+	// The failure belongs to the breaker as it was before restoring.
+	stats := restored.Stats()
+	assert.Equal(t, Closed, stats.State)
+	assert.Equal(t, uint64(1), stats.Requests)
+	assert.Equal(t, uint64(1), stats.Failures)
+}
+
+func TestCountCBSnapshotInvalid(t *testing.T) {
+	t.Parallel()
+
+	for name, snapshot := range map[string]string{
+		"closed at threshold":      `{"state":"closed","closedFailures":2,"stats":{"state":"closed"}}`,
+		"negative failures":        `{"state":"closed","closedFailures":-1,"stats":{"state":"closed"}}`,
+		"closed with rejections":   `{"state":"closed","halfOpenAttempts":1,"stats":{"state":"closed"}}`,
+		"open below threshold":     `{"state":"open","closedFailures":1,"stats":{"state":"open"}}`,
+		"open past rejections":     `{"state":"open","closedFailures":2,"halfOpenAttempts":3,"stats":{"state":"open"}}`,
+		"stats of another state":   `{"state":"open","closedFailures":2,"stats":{"state":"closed"}}`,
+		"more outcomes than calls": `{"state":"closed","stats":{"state":"closed","requests":1,"failures":2}}`,
+		"both consecutive":         `{"state":"closed","stats":{"state":"closed","consecutiveFailures":1,"consecutiveSuccesses":1}}`,
+	} {
+		cb, err := NewCountCB(WithFailureThreshold(2), WithOpenRejections(3))
+		assert.NoError(t, err)
+		_ = cb.Call(Error(t))
+
+		assert.ErrorIs(t, json.Unmarshal([]byte(snapshot), cb), ErrInvalidSnapshot, name)
+		stats := cb.Stats()
+		assert.Equal(t, Closed, stats.State, name)
+		assert.Equal(t, uint64(1), stats.Failures, name)
+	}
+
+	cb, err := NewCountCB()
+	assert.NoError(t, err)
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"state":"broken"}`), cb), `unknown state "broken"`)
+	assert.Error(t, json.Unmarshal([]byte(`[]`), cb))
+
+	// A zero breaker has no thresholds any snapshot could fit.
+	var zero CountCB
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"state":"closed","stats":{"state":"closed"}}`), &zero), ErrInvalidSnapshot)
+}
+
+func TestTimeCBSnapshotInvalid(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	openAt := func(ago time.Duration) string {
+		return `"openAt":"` + now.Add(-ago).Format(time.RFC3339Nano) + `"`
+	}
+	open := `"state":"open","closedFailures":1,"trips":1,"openFor":10000000000,` + openAt(5*time.Second)
+	halfOpen := `"state":"half-open","closedFailures":1,"trips":1,"openFor":10000000000,`
+
+	for name, snapshot := range map[string]string{
+		"closed at threshold":      `{"state":"closed","closedFailures":1,"stats":{"state":"closed"}}`,
+		"closed with open period":  `{"state":"closed",` + openAt(time.Second) + `,"stats":{"state":"closed"}}`,
+		"closed with trips":        `{"state":"closed","trips":1,"stats":{"state":"closed"}}`,
+		"open below threshold":     `{"state":"open","trips":1,"openFor":1,` + openAt(time.Second) + `,"stats":{"state":"open"}}`,
+		"open without openAt":      `{"state":"open","closedFailures":1,"trips":1,"openFor":1,"stats":{"state":"open"}}`,
+		"open without trips":       `{"state":"open","closedFailures":1,"openFor":1,` + openAt(time.Second) + `,"stats":{"state":"open"}}`,
+		"open with probe failures": `{` + open + `,"halfOpenFailures":1,"stats":{"state":"open"}}`,
+		"half-open at threshold":   `{` + halfOpen + openAt(time.Minute) + `,"halfOpenFailures":2,"stats":{"state":"half-open"}}`,
+		"stats of another state":   `{` + open + `,"stats":{"state":"closed"}}`,
+	} {
+		cb, err := NewTimeCB(WithClock(circuittest.NewClock(now)), WithFailureThreshold(1), WithHalfOpenFailureThreshold(2))
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, json.Unmarshal([]byte(snapshot), cb), ErrInvalidSnapshot, name)
+		assert.Equal(t, Closed, cb.State(), name)
+	}
+
+	cb, err := NewTimeCB(WithClock(circuittest.NewClock(now)), WithFailureThreshold(1), WithHalfOpenFailureThreshold(2))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal([]byte(`{`+halfOpen+openAt(time.Minute)+`,"halfOpenFailures":1,"stats":{"state":"half-open"}}`), cb))
+	assert.Equal(t, HalfOpen, cb.State())
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	assert.Equal(t, Open, cb.State())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"openFor":"1s"}`), cb))
+	var zero TimeCB
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"state":"closed","stats":{"state":"closed"}}`), &zero), ErrInvalidSnapshot)
+}
+
+func TestStateText(t *testing.T) {
+	t.Parallel()
+
+	for _, state := range allStates {
+		text, err := state.MarshalText()
+		assert.NoError(t, err)
+
+		var parsed State
+		assert.NoError(t, parsed.UnmarshalText(text))
+		assert.Equal(t, state, parsed)
+	}
+
+	_, err := State(42).MarshalText()
+	assert.ErrorContains(t, err, "unknown state 42")
+
+	data, err := json.Marshal(Stats{State: HalfOpen})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), `{"state":"half-open","requests":0,`))
+}
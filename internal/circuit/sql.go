@@ -0,0 +1,331 @@
+package circuit
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+)
+
+var (
+	errNamedParameters = errors.New("circuit: driver does not support named parameters")
+	errTxOptions       = errors.New("circuit: driver does not support non-default transaction options")
+)
+
+// SQLClassifier counts errors that mean the database could not be reached,
+// driver.ErrBadConn, network errors and deadlines, as failures. Any other
+// error, such as a constraint violation or no rows, is the database working
+// as intended and counts as a success. Canceled calls are ignored.
+func SQLClassifier(err error) Outcome {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, driver.ErrSkip), errors.Is(err, context.Canceled):
+		return OutcomeIgnore
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return OutcomeFailure
+	default:
+		return OutcomeSuccess
+	}
+}
+
+type ConnectorOption func(*sqlBreaker)
+
+// WithSQLClassifier replaces SQLClassifier. It takes the place of the
+// Classifier of the breaker for calls made through the connector.
+func WithSQLClassifier(classify Classifier) ConnectorOption {
+	return func(s *sqlBreaker) {
+		s.classify = classify
+	}
+}
+
+// NewConnector wraps base so connecting, preparing, querying, executing,
+// beginning transactions and pinging all go through b. Rejected calls fail
+// with a *RejectedError. Use it with sql.OpenDB.
+func NewConnector(b Breaker, base driver.Connector, opts ...ConnectorOption) driver.Connector {
+	s := &sqlBreaker{breaker: b, classify: SQLClassifier}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return &connector{sqlBreaker: s, base: base}
+}
+
+type sqlBreaker struct {
+	breaker  Breaker
+	classify Classifier
+}
+
+func (s *sqlBreaker) run(ctx context.Context, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var err error
+	_, rejected := attempt(s.breaker, func() error {
+		err = f()
+		return &classified{outcome: s.classify(err), err: err}
+	})
+	if rejected != nil {
+		return rejected
+	}
+	return err
+}
+
+type connector struct {
+	*sqlBreaker
+	base driver.Connector
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	var base driver.Conn
+	err := c.run(ctx, func() error {
+		var err error
+		base, err = c.base.Connect(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &conn{sqlBreaker: c.sqlBreaker, base: base}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+// Close closes base when it is an io.Closer, as sql.DB.Close would if it was
+// not wrapped.
+func (c *connector) Close() error {
+	if closer, ok := c.base.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+type conn struct {
+	*sqlBreaker
+	base driver.Conn
+}
+
+var (
+	_ driver.Conn               = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+	_ driver.SessionResetter    = (*conn)(nil)
+	_ driver.Validator          = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var base driver.Stmt
+	err := c.run(ctx, func() error {
+		var err error
+		if prepare, ok := c.base.(driver.ConnPrepareContext); ok {
+			base, err = prepare.PrepareContext(ctx, query)
+		} else {
+			base, err = c.base.Prepare(query)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{sqlBreaker: c.sqlBreaker, conn: c.base, base: base}, nil
+}
+
+func (c *conn) Close() error {
+	return c.base.Close()
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	err := c.run(ctx, func() error {
+		var err error
+		if begin, ok := c.base.(driver.ConnBeginTx); ok {
+			tx, err = begin.BeginTx(ctx, opts)
+		} else if opts.Isolation != driver.IsolationLevel(0) || opts.ReadOnly {
+			err = errTxOptions
+		} else {
+			tx, err = c.base.Begin() //nolint:staticcheck
+		}
+		return err
+	})
+	return tx, err
+}
+
+// QueryContext returns driver.ErrSkip when base cannot query directly, so
+// database/sql prepares a statement instead, which goes through the breaker.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.base.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	var rows driver.Rows
+	err := c.run(ctx, func() error {
+		var err error
+		rows, err = queryer.QueryContext(ctx, query, args)
+		return err
+	})
+	return rows, err
+}
+
+// ExecContext falls back like QueryContext does.
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.base.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	var result driver.Result
+	err := c.run(ctx, func() error {
+		var err error
+		result, err = execer.ExecContext(ctx, query, args)
+		return err
+	})
+	return result, err
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	pinger, ok := c.base.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+	return c.run(ctx, func() error {
+		return pinger.Ping(ctx)
+	})
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.base.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if validator, ok := c.base.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	return checkNamedValue(nv, c.base)
+}
+
+// checkNamedValue asks the first of candidates that checks values, or lets
+// database/sql apply its default conversion.
+func checkNamedValue(nv *driver.NamedValue, candidates ...any) error {
+	for _, candidate := range candidates {
+		if checker, ok := candidate.(driver.NamedValueChecker); ok {
+			return checker.CheckNamedValue(nv)
+		}
+	}
+	return driver.ErrSkip
+}
+
+type stmt struct {
+	*sqlBreaker
+	conn driver.Conn
+	base driver.Stmt
+}
+
+var (
+	_ driver.Stmt              = (*stmt)(nil)
+	_ driver.StmtExecContext   = (*stmt)(nil)
+	_ driver.StmtQueryContext  = (*stmt)(nil)
+	_ driver.NamedValueChecker = (*stmt)(nil)
+)
+
+func (s *stmt) Close() error {
+	return s.base.Close()
+}
+
+func (s *stmt) NumInput() int {
+	return s.base.NumInput()
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	err := s.run(ctx, func() error {
+		var err error
+		if execer, ok := s.base.(driver.StmtExecContext); ok {
+			result, err = execer.ExecContext(ctx, args)
+			return err
+		}
+
+		values, err := values(args)
+		if err != nil {
+			return err
+		}
+		result, err = s.base.Exec(values) //nolint:staticcheck
+		return err
+	})
+	return result, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	err := s.run(ctx, func() error {
+		var err error
+		if queryer, ok := s.base.(driver.StmtQueryContext); ok {
+			rows, err = queryer.QueryContext(ctx, args)
+			return err
+		}
+
+		values, err := values(args)
+		if err != nil {
+			return err
+		}
+		rows, err = s.base.Query(values) //nolint:staticcheck
+		return err
+	})
+	return rows, err
+}
+
+// CheckNamedValue defers to the statement and then the connection, the way
+// database/sql would if the statement was not wrapped.
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return checkNamedValue(nv, s.base, s.conn)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+func values(named []driver.NamedValue) ([]driver.Value, error) {
+	args := make([]driver.Value, len(named))
+	for i, nv := range named {
+		if nv.Name != "" {
+			return nil, errNamedParameters
+		}
+		args[i] = nv.Value
+	}
+	return args, nil
+}
@@ -0,0 +1,497 @@
+package circuit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDB is the backend of the fake driver, every operation fails with err
+// while it is set.
+type fakeDB struct {
+	mu    sync.Mutex
+	err   error
+	rows  int
+	calls map[string]int
+}
+
+func newFakeDB(rows int) *fakeDB {
+	return &fakeDB{rows: rows, calls: make(map[string]int)}
+}
+
+func (db *fakeDB) fail(err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.err = err
+}
+
+func (db *fakeDB) call(op string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.calls[op]++
+	return db.err
+}
+
+func (db *fakeDB) count(op string) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.calls[op]
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fake: use a connector")
+}
+
+// fakeConnector hands out connections that only implement the required
+// driver.Conn methods unless withContext is set.
+type fakeConnector struct {
+	db          *fakeDB
+	withContext bool
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	if err := c.db.call("connect"); err != nil {
+		return nil, err
+	}
+	conn := &fakeConn{db: c.db}
+	if c.withContext {
+		return &fakeContextConn{fakeConn: conn}, nil
+	}
+	return conn, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	if err := c.db.call("prepare"); err != nil {
+		return nil, err
+	}
+	return &fakeStmt{db: c.db}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	if err := c.db.call("begin"); err != nil {
+		return nil, err
+	}
+	return fakeTx{}, nil
+}
+
+type fakeContextConn struct {
+	*fakeConn
+}
+
+func (c *fakeContextConn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeContextStmt{fakeStmt: stmt.(*fakeStmt)}, nil
+}
+
+func (c *fakeContextConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return c.Begin()
+}
+
+func (c *fakeContextConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	if err := c.db.call("query"); err != nil {
+		return nil, err
+	}
+	return &fakeRows{left: c.db.rows}, nil
+}
+
+func (c *fakeContextConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.call("exec"); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeContextConn) Ping(context.Context) error {
+	return c.db.call("ping")
+}
+
+func (c *fakeContextConn) ResetSession(context.Context) error {
+	return c.db.call("reset")
+}
+
+func (c *fakeContextConn) IsValid() bool {
+	return c.db.call("valid") == nil
+}
+
+func (c *fakeContextConn) CheckNamedValue(*driver.NamedValue) error {
+	return driver.ErrRemoveArgument
+}
+
+type fakeStmt struct {
+	db *fakeDB
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	if err := s.db.call("exec"); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	if err := s.db.call("query"); err != nil {
+		return nil, err
+	}
+	return &fakeRows{left: s.db.rows}, nil
+}
+
+type fakeContextStmt struct {
+	*fakeStmt
+}
+
+func (s *fakeContextStmt) ExecContext(context.Context, []driver.NamedValue) (driver.Result, error) {
+	return s.Exec(nil)
+}
+
+func (s *fakeContextStmt) QueryContext(context.Context, []driver.NamedValue) (driver.Rows, error) {
+	return s.Query(nil)
+}
+
+func (s *fakeContextStmt) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeRows struct {
+	left int
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"id"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+	r.left--
+	dest[0] = int64(r.left)
+	return nil
+}
+
+func newFakeSQL(t *testing.T, db *fakeDB, withContext bool, opts ...ConnectorOption) (*sql.DB, *CountCB) {
+	t.Helper()
+	cb, err := NewCountCB(WithFailureThreshold(1))
+	assert.NoError(t, err)
+
+	sqlDB := sql.OpenDB(NewConnector(cb, &fakeConnector{db: db, withContext: withContext}, opts...))
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	return sqlDB, cb
+}
+
+func TestConnectorConnectionErrorOpensBreaker(t *testing.T) {
+	t.Parallel()
+
+	db := newFakeDB(1)
+	sqlDB, cb := newFakeSQL(t, db, true)
+
+	db.fail(driver.ErrBadConn)
+	err := sqlDB.PingContext(context.Background())
+
+	// database/sql retries bad connections, the breaker rejects the retries.
+	var rejected *RejectedError
+	assert.ErrorAs(t, err, &rejected)
+	assert.Equal(t, Open, cb.State())
+	assert.Equal(t, 1, db.count("connect"))
+
+	db.fail(nil)
+	_, err = sqlDB.ExecContext(context.Background(), "DELETE FROM users")
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.Equal(t, 0, db.count("exec"))
+}
+
+func TestConnectorNetworkErrorOpensBreaker(t *testing.T) {
+	t.Parallel()
+
+	db := newFakeDB(1)
+	sqlDB, cb := newFakeSQL(t, db, true)
+	assert.NoError(t, sqlDB.PingContext(context.Background()))
+
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	db.fail(reset)
+	var id int
+	err := sqlDB.QueryRowContext(context.Background(), "SELECT id FROM users").Scan(&id)
+	assert.ErrorIs(t, err, reset)
+	assert.Equal(t, Open, cb.State())
+}
+
+func TestConnectorNoRowsIsSuccess(t *testing.T) {
+	t.Parallel()
+
+	db := newFakeDB(0)
+	sqlDB, cb := newFakeSQL(t, db, true)
+
+	var id int
+	err := sqlDB.QueryRowContext(context.Background(), "SELECT id FROM users WHERE id = ?", 1).Scan(&id)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	duplicate := errors.New("duplicate key value violates unique constraint")
+	db.fail(duplicate)
+	_, err = sqlDB.ExecContext(context.Background(), "INSERT INTO users VALUES (?)", 1)
+	assert.ErrorIs(t, err, duplicate)
+
+	stats := cb.Stats()
+	assert.Equal(t, Closed, stats.State)
+	assert.Equal(t, uint64(3), stats.Successes)
+}
+
+func TestConnectorOutsideBreaker(t *testing.T) {
+	t.Parallel()
+
+	db := newFakeDB(1)
+	b := &outsideBreaker{}
+	sqlDB := sql.OpenDB(NewConnector(b, &fakeConnector{db: db, withContext: true}))
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+
+	var id int
+	assert.NoError(t, sqlDB.QueryRowContext(context.Background(), "SELECT id FROM users").Scan(&id))
+	_, err := sqlDB.ExecContext(context.Background(), "DELETE FROM users")
+	assert.NoError(t, err)
+
+	duplicate := errors.New("duplicate key value violates unique constraint")
+	db.fail(duplicate)
+	_, err = sqlDB.ExecContext(context.Background(), "INSERT INTO users VALUES (?)", 1)
+	assert.ErrorIs(t, err, duplicate)
+	assert.Equal(t, 0, b.failures())
+
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	db.fail(reset)
+	_, err = sqlDB.ExecContext(context.Background(), "DELETE FROM users")
+	assert.ErrorIs(t, err, reset)
+	assert.Equal(t, 1, b.failures())
+}
+
+type closerConnector struct {
+	fakeConnector
+	closed bool
+}
+
+func (c *closerConnector) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestConnectorClosesBase(t *testing.T) {
+	t.Parallel()
+
+	cb, err := NewCountCB()
+	assert.NoError(t, err)
+	base := &closerConnector{fakeConnector: fakeConnector{db: newFakeDB(1), withContext: true}}
+	sqlDB := sql.OpenDB(NewConnector(cb, base))
+	assert.NoError(t, sqlDB.PingContext(context.Background()))
+
+	assert.NoError(t, sqlDB.Close())
+	assert.True(t, base.closed)
+
+	// Connectors that do not close anything are fine too.
+	sqlDB = sql.OpenDB(NewConnector(cb, &fakeConnector{db: newFakeDB(1)}))
+	assert.NoError(t, sqlDB.Close())
+}
+
+func TestConnectorWithSQLClassifier(t *testing.T) {
+	t.Parallel()
+
+	db := newFakeDB(0)
+	sqlDB, cb := newFakeSQL(t, db, true, WithSQLClassifier(DefaultClassifier))
+	assert.NoError(t, sqlDB.PingContext(context.Background()))
+
+	db.fail(errors.New("duplicate key value violates unique constraint"))
+	_, _ = sqlDB.ExecContext(context.Background(), "INSERT INTO users VALUES (?)", 1)
+	assert.Equal(t, Open, cb.State())
+}
+
+func TestConnectorPreparesWithoutContextSupport(t *testing.T) {
+	t.Parallel()
+
+	db := newFakeDB(2)
+	sqlDB, cb := newFakeSQL(t, db, false)
+
+	rows, err := sqlDB.QueryContext(context.Background(), "SELECT id FROM users")
+	assert.NoError(t, err)
+	ids := 0
+	for rows.Next() {
+		ids++
+	}
+	assert.NoError(t, rows.Err())
+	assert.NoError(t, rows.Close())
+	assert.Equal(t, 2, ids)
+
+	_, err = sqlDB.ExecContext(context.Background(), "DELETE FROM users WHERE id = ?", 1)
+	assert.NoError(t, err)
+
+	_, err = sqlDB.ExecContext(context.Background(), "DELETE FROM users WHERE id = @id", sql.Named("id", 1))
+	assert.ErrorIs(t, err, errNamedParameters)
+
+	// connect, then prepare and run each of the three statements.
+	assert.Equal(t, uint64(7), cb.Stats().Requests)
+	assert.Equal(t, Closed, cb.State())
+
+	db.fail(driver.ErrBadConn)
+	_, err = sqlDB.ExecContext(context.Background(), "DELETE FROM users")
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.Equal(t, Open, cb.State())
+}
+
+func TestConnectorTransactions(t *testing.T) {
+	t.Parallel()
+
+	for _, withContext := range []bool{true, false} {
+		db := newFakeDB(0)
+		sqlDB, _ := newFakeSQL(t, db, withContext)
+
+		tx, err := sqlDB.BeginTx(context.Background(), nil)
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Commit())
+		assert.Equal(t, 1, db.count("begin"))
+
+		tx, err = sqlDB.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+		if withContext {
+			assert.NoError(t, err)
+			assert.NoError(t, tx.Rollback())
+		} else {
+			assert.ErrorIs(t, err, errTxOptions)
+		}
+	}
+}
+
+func TestConnectorCanceledContext(t *testing.T) {
+	t.Parallel()
+
+	db := newFakeDB(0)
+	_, cb := newFakeSQL(t, db, true)
+	c := NewConnector(cb, &fakeConnector{db: db})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.Connect(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, db.count("connect"))
+	assert.Zero(t, cb.Stats().Requests)
+}
+
+func TestConnWrapperDelegates(t *testing.T) {
+	t.Parallel()
+
+	cb, err := NewCountCB()
+	assert.NoError(t, err)
+	db := newFakeDB(1)
+
+	c := NewConnector(cb, &fakeConnector{db: db})
+	assert.Equal(t, fakeDriver{}, c.Driver())
+
+	plain, err := c.Connect(context.Background())
+	assert.NoError(t, err)
+	wrapped := NewConnector(cb, &fakeConnector{db: db, withContext: true})
+	full, err := wrapped.Connect(context.Background())
+	assert.NoError(t, err)
+
+	// Optional interfaces the base connection lacks have neutral defaults.
+	nv := &driver.NamedValue{Ordinal: 1, Value: 1}
+	assert.NoError(t, plain.(driver.SessionResetter).ResetSession(context.Background()))
+	assert.True(t, plain.(driver.Validator).IsValid())
+	assert.NoError(t, plain.(driver.Pinger).Ping(context.Background()))
+	assert.ErrorIs(t, plain.(driver.NamedValueChecker).CheckNamedValue(nv), driver.ErrSkip)
+	assert.NoError(t, full.(driver.SessionResetter).ResetSession(context.Background()))
+	assert.True(t, full.(driver.Validator).IsValid())
+	assert.Equal(t, 1, db.count("reset"))
+	assert.Equal(t, 1, db.count("valid"))
+	assert.ErrorIs(t, full.(driver.NamedValueChecker).CheckNamedValue(nv), driver.ErrRemoveArgument)
+
+	tx, err := plain.Begin() //nolint:staticcheck
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	for _, conn := range []driver.Conn{plain, full} {
+		stmt, err := conn.Prepare("SELECT id FROM users")
+		assert.NoError(t, err)
+		assert.Equal(t, -1, stmt.NumInput())
+
+		result, err := stmt.Exec([]driver.Value{int64(1)}) //nolint:staticcheck
+		assert.NoError(t, err)
+		affected, err := result.RowsAffected()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), affected)
+
+		rows, err := stmt.Query(nil) //nolint:staticcheck
+		assert.NoError(t, err)
+		assert.NoError(t, rows.Close())
+		assert.NoError(t, stmt.Close())
+		assert.NoError(t, conn.Close())
+	}
+
+	// Statements check values themselves, else through their connection.
+	stmt, err := full.Prepare("SELECT id FROM users")
+	assert.NoError(t, err)
+	assert.NoError(t, stmt.(driver.NamedValueChecker).CheckNamedValue(nv))
+	stmt, err = plain.Prepare("SELECT id FROM users")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, stmt.(driver.NamedValueChecker).CheckNamedValue(nv), driver.ErrSkip)
+
+	assert.Equal(t, uint64(11), cb.Stats().Successes)
+}
+
+func TestSQLClassifier(t *testing.T) {
+	t.Parallel()
+
+	for err, outcome := range map[error]Outcome{
+		nil:                      OutcomeSuccess,
+		sql.ErrNoRows:            OutcomeSuccess,
+		errors.New("syntax"):     OutcomeSuccess,
+		driver.ErrSkip:           OutcomeIgnore,
+		context.Canceled:         OutcomeIgnore,
+		driver.ErrBadConn:        OutcomeFailure,
+		context.DeadlineExceeded: OutcomeFailure,
+		&net.OpError{Op: "dial", Err: errors.New("connection refused")}: OutcomeFailure,
+	} {
+		assert.Equal(t, outcome, SQLClassifier(err), err)
+	}
+}
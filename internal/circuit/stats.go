@@ -0,0 +1,115 @@
+package circuit
+
+import "time"
+
+// Stats is a consistent snapshot of a breaker. Requests counts calls as they
+// arrive and every call ends up in exactly one of the outcome counters once it
+// completes, even if the breaker changed state meanwhile. The difference is
+// the number of calls in flight.
+type Stats struct {
+	State                State     `json:"state"`
+	Requests             uint64    `json:"requests"`
+	Successes            uint64    `json:"successes"`
+	Failures             uint64    `json:"failures"`
+	Slow                 uint64    `json:"slow"`
+	TimedOut             uint64    `json:"timedOut"`
+	Ignored              uint64    `json:"ignored"`
+	Rejections           uint64    `json:"rejections"`
+	TooManyProbes        uint64    `json:"tooManyProbes"`
+	Overrides            uint64    `json:"overrides"`
+	ConsecutiveFailures  uint64    `json:"consecutiveFailures"`
+	ConsecutiveSuccesses uint64    `json:"consecutiveSuccesses"`
+	LastTransition       time.Time `json:"lastTransition"`
+}
+
+// counters is embedded in every breaker and guarded by the breaker lock.
+type counters struct {
+	stats Stats
+}
+
+func (c *counters) admit() {
+	c.stats.Requests++
+}
+
+func (c *counters) reject(result Result) {
+	c.stats.Requests++
+	switch result {
+	case Rejected:
+		c.stats.Rejections++
+	case TooManyProbes:
+		c.stats.TooManyProbes++
+	default:
+		panic("unreachable")
+	}
+}
+
+// complete records the outcome of an admitted call, failure tells whether
+// the breaker counts it against the backend.
+func (c *counters) complete(result Result, failure bool) {
+	switch result {
+	case Succeeded:
+		c.stats.Successes++
+	case Failed:
+		c.stats.Failures++
+	case Slow:
+		c.stats.Slow++
+	case TimedOut:
+		c.stats.TimedOut++
+	case Ignored:
+		c.stats.Ignored++
+		return
+	default:
+		panic("unreachable")
+	}
+
+	if failure {
+		c.stats.ConsecutiveFailures++
+		c.stats.ConsecutiveSuccesses = 0
+	} else {
+		c.stats.ConsecutiveSuccesses++
+		c.stats.ConsecutiveFailures = 0
+	}
+}
+
+// override counts a ForceOpen, ForceClosed or Reset.
+func (c *counters) override() {
+	c.stats.Overrides++
+}
+
+func (c *counters) transition(now time.Time) {
+	c.stats.LastTransition = now
+}
+
+func (c *counters) snapshot(state State) Stats {
+	stats := c.stats
+	stats.State = state
+	return stats
+}
+
+// validate checks stats restored from a snapshot of a breaker in state.
+func (s Stats) validate(state State) error {
+	if s.State != state {
+		return invalidSnapshot("stats of a %s breaker in a %s snapshot", s.State, state)
+	}
+
+	if completed := s.completed(); completed > s.Requests {
+		return invalidSnapshot("%d outcomes for %d requests", completed, s.Requests)
+	}
+	if s.ConsecutiveFailures > 0 && s.ConsecutiveSuccesses > 0 {
+		return invalidSnapshot("both consecutive failures and successes")
+	}
+	return nil
+}
+
+func (s Stats) completed() uint64 {
+	return s.Successes + s.Failures + s.Slow + s.TimedOut + s.Ignored + s.Rejections + s.TooManyProbes
+}
+
+// restore replaces the counters with stats from a snapshot. Calls that were
+// in flight when it was taken never complete here, so Requests drops them
+// and keeps the calls in flight in this breaker instead.
+func (c *counters) restore(stats Stats) {
+	inFlight := c.stats.Requests - c.stats.completed()
+	stats.Requests = stats.completed() + inFlight
+	c.stats = stats
+}
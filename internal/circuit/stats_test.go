@@ -0,0 +1,172 @@
+package circuit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+func outcomes(s Stats) uint64 {
+	return s.Successes + s.Failures + s.Slow + s.TimedOut + s.Ignored + s.Rejections + s.TooManyProbes
+}
+
+func TestStatsInitial(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range newBreakers(t, circuittest.NewClock(time.Now())) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, Stats{State: Closed}, b.Stats())
+		})
+	}
+}
+
+func TestCountStats(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	c, err := NewCountCB(
+		WithClock(clock),
+		WithFailureThreshold(3),
+		WithOpenRejections(2),
+		WithClassifier(ignoreCanceled),
+		WithSlowCallThreshold(time.Second),
+	)
+	assert.NoError(t, err)
+
+	_ = c.Call(Ok(t))
+	_ = c.Call(Ok(t))
+	_ = c.Call(func() error { return context.Canceled })
+	_ = c.Call(Error(t))
+	_ = c.Call(slowOk(clock, 2*time.Second))
+	assert.Equal(t, Stats{
+		State:               Closed,
+		Requests:            5,
+		Successes:           2,
+		Failures:            1,
+		Slow:                1,
+		Ignored:             1,
+		ConsecutiveFailures: 2,
+	}, c.Stats())
+
+	_ = c.Call(func() error { return context.DeadlineExceeded })
+	openedAt := clock.Now()
+	clock.Advance(2 * time.Second)
+	_ = c.Call(Ok(t))
+
+	stats := c.Stats()
+	assert.Equal(t, Open, stats.State)
+	assert.Equal(t, uint64(7), stats.Requests)
+	assert.Equal(t, uint64(1), stats.TimedOut)
+	assert.Equal(t, uint64(1), stats.Rejections)
+	assert.Equal(t, uint64(3), stats.ConsecutiveFailures)
+	assert.Equal(t, uint64(0), stats.ConsecutiveSuccesses)
+	assert.Equal(t, openedAt, stats.LastTransition)
+	assert.Equal(t, stats.Requests, outcomes(stats))
+}
+
+func TestTimeStatsCountsProbeRejectionsAndStaleOutcomes(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithFailureThreshold(1))
+	assert.NoError(t, err)
+
+	_ = cb.Call(Error(t))
+	clock.Advance(2 * time.Millisecond)
+	_ = cb.Call(func() error {
+		assert.Equal(t, TooManyProbes, cb.Call(Ok(t)))
+		return nil
+	})
+
+	stats := cb.Stats()
+	assert.Equal(t, Closed, stats.State)
+	assert.Equal(t, uint64(3), stats.Requests)
+	assert.Equal(t, uint64(1), stats.Successes)
+	assert.Equal(t, uint64(1), stats.Failures)
+	assert.Equal(t, uint64(1), stats.TooManyProbes)
+	assert.Equal(t, uint64(1), stats.ConsecutiveSuccesses)
+	assert.Equal(t, clock.Now(), stats.LastTransition)
+
+	// An outcome from a previous generation still shows up in the counters.
+	_ = cb.Call(func() error {
+		_ = cb.Call(Error(t))
+		return nil
+	})
+	stats = cb.Stats()
+	assert.Equal(t, Open, stats.State)
+	assert.Equal(t, uint64(2), stats.Successes)
+	assert.Equal(t, uint64(2), stats.Failures)
+	assert.Equal(t, stats.Requests, outcomes(stats))
+}
+
+func TestRateStatsSlowCallsAreNotSuccesses(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewRateCB(
+		WithClock(clock),
+		WithWindowSize(10),
+		WithSlowCallThreshold(time.Second),
+		WithSlowCallRateThreshold(90),
+	)
+	assert.NoError(t, err)
+
+	_ = cb.Call(Ok(t))
+	_ = cb.Call(slowOk(clock, 2*time.Second))
+	stats := cb.Stats()
+	assert.Equal(t, uint64(1), stats.Slow)
+	assert.Equal(t, uint64(1), stats.ConsecutiveFailures)
+	assert.Equal(t, uint64(0), stats.ConsecutiveSuccesses)
+}
+
+func TestStatsConsistentUnderConcurrency(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("slow/integration: stats concurrent sim")
+	}
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(5*time.Millisecond), WithFailureThreshold(10), WithMaxHalfOpenProbes(2))
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for client := range 8 {
+		steps := generateRandomStepsTime(t, int64(client), 5_000)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, step := range steps {
+				switch step {
+				case TimeSuccess:
+					_ = cb.Call(Ok(t))
+				case TimeFailure:
+					_ = cb.Call(Error(t))
+				case TimeTick:
+					clock.Advance(time.Millisecond)
+				default:
+					panic("unreachable")
+				}
+				stats := cb.Stats()
+				assert.LessOrEqual(t, outcomes(stats), stats.Requests)
+				assert.LessOrEqual(t, stats.Requests-outcomes(stats), uint64(8))
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := cb.Stats()
+	assert.Equal(t, stats.Requests, outcomes(stats))
+}
+
+func TestCountersPanicOnUnknownResult(t *testing.T) {
+	t.Parallel()
+
+	var c counters
+	assert.Panics(t, func() { c.reject(Succeeded) })
+	assert.Panics(t, func() { c.complete(Rejected, false) })
+}
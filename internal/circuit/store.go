@@ -0,0 +1,155 @@
+package circuit
+
+import (
+	"sync"
+	"time"
+)
+
+// Trip is the decision of a breaker to open, shared with the breakers of the
+// same name through a Store. OpenFor is zero for CountCB, which does not open
+// for a period of time.
+type Trip struct {
+	OpenAt  time.Time     `json:"openAt"`
+	OpenFor time.Duration `json:"openFor"`
+}
+
+// Store shares trip decisions between breakers, typically the ones of the
+// same backend in every replica of a service. A breaker saves a Trip when it
+// opens and deletes it when it closes. While closed, it opens too when the
+// trip is newer than its last transition.
+//
+// Trips are loaded at most once per WithStoreRefresh, every other call uses
+// the trip loaded last. The store is never called while holding the breaker
+// lock, and the breaker carries on with its own decisions when it fails.
+type Store interface {
+	Load(name string) (Trip, bool, error)
+	Save(name string, trip Trip) error
+	Delete(name string) error
+}
+
+// MemoryStore shares trips between breakers of the same process.
+type MemoryStore struct {
+	mu    sync.Mutex
+	trips map[string]Trip
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{trips: make(map[string]Trip)}
+}
+
+func (s *MemoryStore) Load(name string) (Trip, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trip, ok := s.trips[name]
+	return trip, ok, nil
+}
+
+func (s *MemoryStore) Save(name string, trip Trip) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trips[name] = trip
+	return nil
+}
+
+func (s *MemoryStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.trips, name)
+	return nil
+}
+
+// tripCache holds the trip loaded last from the store of a breaker.
+type tripCache struct {
+	mu       sync.Mutex
+	trip     Trip
+	ok       bool
+	loadedAt time.Time
+	loading  bool
+}
+
+// load returns the cached trip, loading it first when it is older than
+// WithStoreRefresh. Calls arriving while it loads get the cached trip rather
+// than waiting for the store, a failed load keeps it until the next refresh.
+func (c *tripCache) load(o *options) (Trip, bool) {
+	if o.store == nil {
+		return Trip{}, false
+	}
+
+	now := o.clock.Now()
+	c.mu.Lock()
+	if c.loading || (!c.loadedAt.IsZero() && now.Sub(c.loadedAt) < o.storeRefresh) {
+		defer c.mu.Unlock()
+		return c.trip, c.ok
+	}
+	c.loading = true
+	c.mu.Unlock()
+
+	trip, ok, err := o.store.Load(o.name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loading = false
+	c.loadedAt = now
+	if err == nil {
+		c.trip, c.ok = trip, ok
+	}
+	return c.trip, c.ok
+}
+
+// tripWrite is a save of trip, or a delete when remove is set.
+type tripWrite struct {
+	trip   Trip
+	remove bool
+}
+
+// tripWriter queues the writes of a breaker to its store under the breaker
+// lock and performs them once the lock is released, in the order they were
+// made, the way notifier delivers transitions.
+type tripWriter struct {
+	mu      sync.Mutex
+	pending []tripWrite
+	writing bool
+}
+
+func (w *tripWriter) save(o *options, trip Trip) {
+	w.enqueue(o, tripWrite{trip: trip})
+}
+
+func (w *tripWriter) delete(o *options) {
+	w.enqueue(o, tripWrite{remove: true})
+}
+
+func (w *tripWriter) enqueue(o *options, write tripWrite) {
+	if o.store == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, write)
+}
+
+// flush performs the pending writes unless somebody else already is.
+func (w *tripWriter) flush(o *options) {
+	w.mu.Lock()
+	if w.writing {
+		w.mu.Unlock()
+		return
+	}
+	w.writing = true
+
+	for len(w.pending) > 0 {
+		next := w.pending[0]
+		w.pending = w.pending[1:]
+		w.mu.Unlock()
+
+		if next.remove {
+			_ = o.store.Delete(o.name)
+		} else {
+			_ = o.store.Save(o.name, next.trip)
+		}
+
+		w.mu.Lock()
+	}
+	w.writing = false
+	w.mu.Unlock()
+}
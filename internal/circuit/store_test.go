@@ -0,0 +1,288 @@
+package circuit
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	_, ok, err := store.Load("db")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	trip := Trip{OpenAt: time.Now(), OpenFor: time.Second}
+	assert.NoError(t, store.Save("db", trip))
+	loaded, ok, err := store.Load("db")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, trip, loaded)
+
+	assert.NoError(t, store.Delete("db"))
+	_, ok, err = store.Load("db")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestTimeCBSharesTrips(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	clock := circuittest.NewClock(time.Now())
+	opts := []Option{WithClock(clock), WithFailureThreshold(2), WithOpenTimeout(10 * time.Second), WithStore(store), WithName("db")}
+	replica1, err := NewTimeCB(opts...)
+	assert.NoError(t, err)
+	replica2, err := NewTimeCB(opts...)
+	assert.NoError(t, err)
+	other, err := NewTimeCB(WithClock(clock), WithStore(store), WithName("cache"))
+	assert.NoError(t, err)
+
+	_ = replica1.Call(Error(t))
+	_ = replica1.Call(Error(t))
+	assert.Equal(t, Open, replica1.State())
+	clock.Advance(4 * time.Second)
+
+	// The second replica opens without paying the failure threshold, for
+	// what is left of the open period.
+	assert.Equal(t, Rejected, replica2.Call(Ok(t)))
+	assert.Equal(t, Open, replica2.State())
+	assert.Equal(t, 6*time.Second, replica2.OpenRemaining())
+	assert.Equal(t, Succeeded, other.Call(Ok(t)))
+
+	// Closing deletes the trip.
+	clock.Advance(4 * time.Second)
+	clock.Advance(4 * time.Second)
+	assert.Equal(t, Succeeded, replica2.Call(Ok(t)))
+	assert.Equal(t, Closed, replica2.State())
+	_, ok, _ := store.Load("db")
+	assert.False(t, ok)
+
+	// A trip that already expired is not adopted.
+	assert.NoError(t, store.Save("db", Trip{OpenAt: clock.Now().Add(-time.Minute), OpenFor: time.Second}))
+	assert.Equal(t, Succeeded, other.Call(Ok(t)))
+	fresh, err := NewTimeCB(opts...)
+	assert.NoError(t, err)
+	assert.Equal(t, Succeeded, fresh.Call(Ok(t)))
+}
+
+func TestCountCBSharesTrips(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	clock := circuittest.NewClock(time.Now())
+	opts := []Option{WithClock(clock), WithFailureThreshold(1), WithOpenRejections(2), WithStore(store), WithName("db")}
+	replica1, err := NewCountCB(opts...)
+	assert.NoError(t, err)
+	replica2, err := NewCountCB(opts...)
+	assert.NoError(t, err)
+
+	_ = replica1.Call(Error(t))
+	clock.Advance(time.Second)
+	trip, ok, err := store.Load("db")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Zero(t, trip.OpenFor)
+
+	// Adopting the trip spends the first open rejection.
+	assert.Equal(t, Rejected, replica2.Call(Ok(t)))
+	assert.Equal(t, Rejected, replica2.Call(Ok(t)))
+	assert.Equal(t, HalfOpen, replica2.State())
+
+	// A failed probe trips it again.
+	clock.Advance(time.Second)
+	assert.Equal(t, Failed, replica2.Call(Error(t)))
+	reopened, _, _ := store.Load("db")
+	assert.True(t, reopened.OpenAt.After(trip.OpenAt))
+
+	_ = replica2.Call(Ok(t))
+	_ = replica2.Call(Ok(t))
+	assert.Equal(t, Succeeded, replica2.Call(Ok(t)))
+	_, ok, _ = store.Load("db")
+	assert.False(t, ok)
+
+	// replica1 already went through the trip it saved, it is not adopted
+	// again once it closes.
+	_ = replica1.Call(Ok(t))
+	_ = replica1.Call(Ok(t))
+	assert.Equal(t, Succeeded, replica1.Call(Ok(t)))
+	assert.NoError(t, store.Save("db", Trip{OpenAt: clock.Now()}))
+	assert.Equal(t, Succeeded, replica1.Call(Ok(t)))
+}
+
+type failingStore struct{}
+
+func (failingStore) Load(string) (Trip, bool, error) {
+	return Trip{}, false, errors.New("store is down")
+}
+
+func (failingStore) Save(string, Trip) error {
+	return errors.New("store is down")
+}
+
+func (failingStore) Delete(string) error {
+	return errors.New("store is down")
+}
+
+func TestBreakerIgnoresStoreErrors(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithFailureThreshold(1), WithOpenTimeout(time.Second), WithStore(failingStore{}), WithName("db"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	assert.Equal(t, Open, cb.State())
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	assert.Equal(t, Closed, cb.State())
+}
+
+func TestRegistryNamesBreakersInStore(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	replica1, err := NewRegistry(NewTimeCB, WithFailureThreshold(1), WithStore(store))
+	assert.NoError(t, err)
+	replica2, err := NewRegistry(NewTimeCB, WithFailureThreshold(1), WithStore(store))
+	assert.NoError(t, err)
+
+	_ = replica1.Get("db").Call(Error(t))
+	assert.Equal(t, Rejected, replica2.Get("db").Call(Ok(t)))
+	assert.Equal(t, Succeeded, replica2.Get("cache").Call(Ok(t)))
+	assert.Panics(t, func() {
+		replica1.Get("")
+	})
+
+	cb, err := replica1.Breaker("")
+	assert.Nil(t, cb)
+	var field *FieldError
+	assert.ErrorAs(t, err, &field)
+	assert.Equal(t, "name", field.Field)
+	_, ok := replica1.Lookup("")
+	assert.False(t, ok)
+}
+
+func TestStoreRequiresName(t *testing.T) {
+	t.Parallel()
+
+	cb, err := NewCountCB(WithStore(NewMemoryStore()))
+	assert.Nil(t, cb)
+	var field *FieldError
+	assert.ErrorAs(t, err, &field)
+	assert.Equal(t, "name", field.Field)
+
+	_, err = NewTimeCB(WithName("db"))
+	assert.NoError(t, err)
+}
+
+// countingStore counts loads and blocks them, and saves, while gate is held.
+type countingStore struct {
+	*MemoryStore
+	loads   atomic.Int32
+	gate    sync.Mutex
+	loading chan struct{}
+	saving  chan struct{}
+}
+
+func (s *countingStore) Load(name string) (Trip, bool, error) {
+	s.loads.Add(1)
+	if s.loading != nil {
+		s.loading <- struct{}{}
+	}
+	s.gate.Lock()
+	defer s.gate.Unlock()
+	return s.MemoryStore.Load(name)
+}
+
+func (s *countingStore) Save(name string, trip Trip) error {
+	if s.saving != nil {
+		s.saving <- struct{}{}
+	}
+	s.gate.Lock()
+	defer s.gate.Unlock()
+	return s.MemoryStore.Save(name, trip)
+}
+
+func TestBreakerLoadsTripsOncePerRefresh(t *testing.T) {
+	t.Parallel()
+
+	store := &countingStore{MemoryStore: NewMemoryStore()}
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithStore(store), WithName("db"), WithStoreRefresh(time.Second))
+	assert.NoError(t, err)
+
+	for range 3 {
+		assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	}
+	assert.Equal(t, int32(1), store.loads.Load())
+
+	// A trip saved meanwhile is only seen once the refresh is due.
+	clock.Advance(time.Millisecond)
+	assert.NoError(t, store.Save("db", Trip{OpenAt: clock.Now(), OpenFor: time.Minute}))
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	clock.Advance(time.Second)
+	assert.Equal(t, Rejected, cb.Call(Ok(t)))
+	assert.Equal(t, int32(2), store.loads.Load())
+}
+
+func TestBreakerDoesNotWaitForStoreLoads(t *testing.T) {
+	t.Parallel()
+
+	store := &countingStore{MemoryStore: NewMemoryStore(), loading: make(chan struct{}, 1)}
+	cb, err := NewCountCB(WithStore(store), WithName("db"), WithStoreRefresh(0))
+	assert.NoError(t, err)
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	<-store.loading
+
+	store.gate.Lock()
+	done := make(chan Result)
+	go func() {
+		done <- cb.Call(Ok(t))
+	}()
+	<-store.loading
+
+	// The load in flight holds neither the breaker lock nor other calls.
+	assert.Equal(t, Closed, cb.State())
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+	store.gate.Unlock()
+	assert.Equal(t, Succeeded, <-done)
+	assert.Equal(t, int32(2), store.loads.Load())
+}
+
+func TestBreakerDoesNotWaitForStoreWrites(t *testing.T) {
+	t.Parallel()
+
+	store := &countingStore{MemoryStore: NewMemoryStore(), saving: make(chan struct{})}
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithFailureThreshold(1), WithOpenTimeout(time.Second), WithStore(store), WithName("db"))
+	assert.NoError(t, err)
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+
+	store.gate.Lock()
+	done := make(chan Result)
+	go func() {
+		done <- cb.Call(Error(t))
+	}()
+	<-store.saving
+
+	// The save in flight holds neither the breaker lock nor other calls.
+	assert.Equal(t, Open, cb.State())
+	assert.Equal(t, Rejected, cb.Call(Ok(t)))
+	store.gate.Unlock()
+	assert.Equal(t, Failed, <-done)
+
+	trip, ok, err := store.Load("db")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, trip.OpenFor)
+}
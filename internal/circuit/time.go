@@ -1,12 +1,17 @@
 package circuit
 
 import (
-	"fmt"
+	"context"
 	"time"
 )
 
+// Clock is the source of time of the breakers, circuittest.Clock is a
+// manual one for tests.
 type Clock interface {
 	Now() time.Time
+	// AfterFunc calls f once d has elapsed and returns a function that
+	// cancels the call, reporting whether it did, like time.Timer.Stop.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
 }
 
 type RealClock struct{}
@@ -15,114 +20,260 @@ func (c *RealClock) Now() time.Time {
 	return time.Now()
 }
 
+func (c *RealClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
 type TimeCB struct {
+	core
 	clock                   Clock
-	state                   State
-	openTimeout             time.Duration
+	openFor                 time.Duration
+	trips                   int
 	openAt                  *time.Time
-	closedFailures          uint8
-	closedFailuresThreshold uint8
-	halfOpenProbes          uint8
-	halfOpenProbesThreshold uint8
+	closedFailures          int
+	closedFailuresThreshold int
+	halfOpenProbes          int
+	halfOpenProbesThreshold int
+	stopTimer               func() bool
+	closed                  bool
 }
 
-func NewTimeCB(clock Clock, openTimeout time.Duration, halfOpenProbesThreshold, closedFailuresThreshold uint8) (*TimeCB, error) {
-	if openTimeout > 5*time.Second || openTimeout <= 0*time.Second {
-		return nil, fmt.Errorf("openTimeout: 0 < %q < 5", openTimeout)
+func NewTimeCB(opts ...Option) (*TimeCB, error) {
+	o, err := newOptions(opts, backoffConstraint)
+	if err != nil {
+		return nil, err
 	}
 
-	if halfOpenProbesThreshold <= 0 {
-		return nil, fmt.Errorf("halfOpenProbesThreshold: %q <= 0", halfOpenProbesThreshold)
-	}
-
-	if closedFailuresThreshold <= 0 {
-		return nil, fmt.Errorf("closedFailuresThreshold: %q <= 0", closedFailuresThreshold)
-	}
-
-	return &TimeCB{
-		clock:                   clock,
-		state:                   Closed,
-		openTimeout:             openTimeout,
+	c := &TimeCB{
+		core:                    core{opts: o, state: Closed},
+		clock:                   o.clock,
 		openAt:                  nil,
 		closedFailures:          0,
-		closedFailuresThreshold: closedFailuresThreshold,
+		closedFailuresThreshold: o.failureThreshold,
 		halfOpenProbes:          0,
-		halfOpenProbesThreshold: halfOpenProbesThreshold,
-	}, nil
+		halfOpenProbesThreshold: o.halfOpenFailureThreshold,
+	}
+	c.forget = func() {
+		c.stop()
+		c.closedFailures = 0
+		c.halfOpenProbes = 0
+		c.openAt = nil
+		c.openFor = 0
+		c.trips = 0
+	}
+	return c, nil
 }
 
 func (c *TimeCB) Call(f func() error) Result {
+	result, _ := call(c, f)
+	return result
+}
+
+func (c *TimeCB) CallContext(ctx context.Context, f func(context.Context) error) Result {
+	return callContext(ctx, c, f)
+}
+
+func (c *TimeCB) Execute(f func() error) error {
+	return execute(c, f)
+}
+
+func (c *TimeCB) ExecuteContext(ctx context.Context, f func(context.Context) error) error {
+	return executeContext(ctx, c, f)
+}
+
+func (c *TimeCB) CallWithFallback(f func() error, fallback Fallback) error {
+	return callWithFallback(c, f, fallback)
+}
+
+func (c *TimeCB) Allow() (func(err error), bool) {
+	return allow(c)
+}
+
+func (c *TimeCB) before() (uint64, *RejectedError) {
+	trip, ok := c.shared.load(&c.opts)
+	c.mu.Lock()
+	defer c.unlock()
+
 	switch c.state {
 	case Closed:
 		asserts(c.closedFailures < c.closedFailuresThreshold)
 		asserts(c.halfOpenProbes == 0)
 		asserts(c.openAt == nil)
 
-		if err := f(); err != nil {
-			c.closedFailures++
-			if c.closedFailures == c.closedFailuresThreshold {
-				c.state = Open
-				now := c.clock.Now()
-				c.openAt = &now
-			}
-			return Failed
+		if !c.adopt(trip, ok) {
+			c.counters.admit()
+			return c.generation, nil
 		}
-		c.closedFailures = 0
-		return Succeeded
+		fallthrough
 	case Open:
 		asserts(c.closedFailures == c.closedFailuresThreshold)
 		asserts(c.halfOpenProbes == 0)
 		asserts(c.openAt != nil)
 
 		openAtvalue := *c.openAt
-		if c.clock.Now().After(openAtvalue.Add(c.openTimeout)) {
-			c.state = HalfOpen
+		if c.clock.Now().After(openAtvalue.Add(c.openFor)) {
+			c.stop()
+			c.setState(HalfOpen)
 			c.halfOpenProbes = 0
+			asserts(c.probe())
+			c.counters.admit()
+			return c.generation, nil
+		}
+		return c.generation, c.reject(Rejected)
+	case HalfOpen:
+		asserts(c.closedFailures == c.closedFailuresThreshold)
+		asserts(c.halfOpenProbes < c.halfOpenProbesThreshold)
+		// The open period is over, even if the clock went back since.
+		asserts(c.openAt != nil)
 
-			if err := f(); err != nil {
-				c.halfOpenProbes++
-				if c.halfOpenProbes == c.halfOpenProbesThreshold {
-					c.state = Open
-					c.halfOpenProbes = 0
-					now := c.clock.Now()
-					c.openAt = &now
-				}
-				return Failed
+		if !c.probe() {
+			return c.generation, c.reject(TooManyProbes)
+		}
+		c.counters.admit()
+		return c.generation, nil
+	case ForcedOpen:
+		return c.generation, c.reject(Rejected)
+	case ForcedClosed:
+		c.counters.admit()
+		return c.generation, nil
+	default:
+		panic("unreachable")
+	}
+}
+
+func (c *TimeCB) after(generation uint64, result Result) Result {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.counters.complete(result, result != Succeeded)
+	if !c.settle(generation) || result == Ignored {
+		return result
+	}
+
+	switch c.state {
+	case Closed:
+		asserts(c.closedFailures < c.closedFailuresThreshold)
+		asserts(c.halfOpenProbes == 0)
+		asserts(c.openAt == nil)
+
+		if result != Succeeded {
+			c.closedFailures++
+			if c.closedFailures == c.closedFailuresThreshold {
+				c.open()
 			}
-			c.state = Closed
-			c.closedFailures = 0
-			c.openAt = nil
-			c.halfOpenProbes = 0
-			return Succeeded
+			return result
 		}
-		return Rejected
+		c.closedFailures = 0
+		return result
 	case HalfOpen:
 		asserts(c.closedFailures == c.closedFailuresThreshold)
 		asserts(c.halfOpenProbes < c.halfOpenProbesThreshold)
 		asserts(c.openAt != nil)
-		openAtValue := *c.openAt
-		asserts(c.clock.Now().After(openAtValue.Add(c.openTimeout)))
 
-		if err := f(); err != nil {
+		if result != Succeeded {
 			c.halfOpenProbes++
 			if c.halfOpenProbes == c.halfOpenProbesThreshold {
-				c.state = Open
+				c.open()
 				c.halfOpenProbes = 0
-				now := c.clock.Now()
-				c.openAt = &now
 			}
-			return Failed
+			return result
 		}
-		c.state = Closed
+		c.setState(Closed)
 		c.closedFailures = 0
 		c.openAt = nil
+		c.openFor = 0
+		c.trips = 0
 		c.halfOpenProbes = 0
-		return Succeeded
+		c.writer.delete(&c.opts)
+		return result
+	case ForcedClosed:
+		return result
 	default:
 		panic("unreachable")
 	}
 }
 
-func (c *TimeCB) State() State {
-	return c.state
+// open trips the breaker, every trip since it last closed stays open longer.
+func (c *TimeCB) open() {
+	c.setState(Open)
+	c.openFor = c.opts.backoff(c.trips)
+	c.trips++
+	now := c.clock.Now()
+	c.openAt = &now
+	c.writer.save(&c.opts, Trip{OpenAt: now, OpenFor: c.openFor})
+	c.schedule()
+}
+
+// adopt opens the breaker on a trip shared through the store since it last
+// changed state, for what is left of its open period.
+func (c *TimeCB) adopt(trip Trip, ok bool) bool {
+	if !c.newer(trip, ok) || !c.clock.Now().Before(trip.OpenAt.Add(trip.OpenFor)) {
+		return false
+	}
+	c.setState(Open)
+	c.closedFailures = c.closedFailuresThreshold
+	c.openFor = trip.OpenFor
+	c.trips++
+	c.openAt = &trip.OpenAt
+	c.schedule()
+	return true
+}
+
+// schedule arms the timer that ends the open period when
+// WithScheduledHalfOpen is set. The period ends strictly after openFor, as
+// it does for calls.
+func (c *TimeCB) schedule() {
+	if !c.opts.scheduleHalfOpen || c.closed {
+		return
+	}
+
+	generation := c.generation
+	remaining := c.openAt.Add(c.openFor).Sub(c.clock.Now())
+	c.stopTimer = c.clock.AfterFunc(remaining+time.Nanosecond, func() {
+		c.mu.Lock()
+		defer c.unlock()
+
+		// Stopping the timer loses the race against a timer already
+		// waiting for the lock.
+		if c.generation != generation {
+			return
+		}
+		asserts(c.state == Open)
+		c.stopTimer = nil
+		c.setState(HalfOpen)
+	})
+}
+
+// Close stops the timer of WithScheduledHalfOpen and the ones expiring the
+// calls reserved by Allow. The breaker keeps working, but only leaves the
+// open state on calls from then on, and reserved calls only end with done.
+func (c *TimeCB) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.stop()
+	c.reserved.close()
+	return nil
+}
+
+// stop disarms the timer of schedule. It is only armed while open, so every
+// way out of the open state stops it.
+func (c *TimeCB) stop() {
+	if c.stopTimer != nil {
+		c.stopTimer()
+		c.stopTimer = nil
+	}
+}
+
+// OpenRemaining is how long the breaker stays open before it lets a probe
+// through, zero when it is not open.
+func (c *TimeCB) OpenRemaining() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != Open {
+		return 0
+	}
+	return max(c.openAt.Add(c.openFor).Sub(c.clock.Now()), 0)
 }
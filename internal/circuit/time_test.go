@@ -5,55 +5,58 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vrnvu/go-project-template/internal/circuit/circuittest"
 )
 
-type TestClock struct {
-	now        time.Time
-	tickAmount time.Duration
-}
-
-func NewTestClock(now time.Time, tickAmount time.Duration) *TestClock {
-	return &TestClock{now: now, tickAmount: tickAmount}
-}
-
-func (c *TestClock) Now() time.Time {
-	return c.now
-}
-
-func (c *TestClock) Tick() {
-	c.now = c.now.Add(c.tickAmount)
-}
+var _ Clock = (*circuittest.Clock)(nil)
 
 func TestNewTimeCBInvalid(t *testing.T) {
 	t.Parallel()
 
-	c, err := NewTimeCB(NewTestClock(time.Now(), time.Millisecond), 0*time.Second, 1, 1)
+	c, err := NewTimeCB(WithClock(circuittest.NewClock(time.Now())), WithOpenTimeout(0*time.Second), WithHalfOpenFailureThreshold(1), WithFailureThreshold(1))
 	assert.Nil(t, c)
 	assert.ErrorContains(t, err, "openTimeout")
 
-	c, err = NewTimeCB(NewTestClock(time.Now(), time.Millisecond), 1*time.Second, 0, 1)
+	c, err = NewTimeCB(WithClock(circuittest.NewClock(time.Now())), WithOpenTimeout(1*time.Second), WithHalfOpenFailureThreshold(0), WithFailureThreshold(1))
 	assert.Nil(t, c)
-	assert.ErrorContains(t, err, "halfOpenProbesThreshold")
+	assert.ErrorContains(t, err, "halfOpenFailureThreshold")
 
-	c, err = NewTimeCB(NewTestClock(time.Now(), time.Millisecond), 1*time.Second, 1, 0)
+	c, err = NewTimeCB(WithClock(circuittest.NewClock(time.Now())), WithOpenTimeout(1*time.Second), WithHalfOpenFailureThreshold(1), WithFailureThreshold(0))
 	assert.Nil(t, c)
-	assert.ErrorContains(t, err, "closedFailuresThreshold")
+	assert.ErrorContains(t, err, "failureThreshold")
+}
 
-	c, err = NewTimeCB(NewTestClock(time.Now(), time.Millisecond), 6*time.Second, 1, 1)
-	assert.Nil(t, c)
-	assert.ErrorContains(t, err, "openTimeout")
+func TestNewTimeCBAcceptsLongOpenTimeout(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(10*time.Minute), WithFailureThreshold(1_000))
+	assert.NoError(t, err)
+
+	for range 1_000 {
+		_ = cb.Call(Error(t))
+	}
+	assert.Equal(t, Open, cb.State())
+
+	for range 10 {
+		clock.Advance(time.Minute)
+		assert.Equal(t, Rejected, cb.Call(Ok(t)))
+	}
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
 }
 
 func TestTimeClosedSuccess(t *testing.T) {
 	t.Parallel()
 
 	start := time.Now()
-	clock := NewTestClock(start, 2*time.Millisecond)
+	clock := circuittest.NewClock(start)
 	openTimeout := time.Millisecond
-	halfOpenProbesThreshold := uint8(1)
-	closedFailuresThreshold := uint8(2)
+	halfOpenProbesThreshold := 1
+	closedFailuresThreshold := 2
 
-	cb, err := NewTimeCB(clock, openTimeout, halfOpenProbesThreshold, closedFailuresThreshold)
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(openTimeout), WithHalfOpenFailureThreshold(halfOpenProbesThreshold), WithFailureThreshold(closedFailuresThreshold))
 	assert.NoError(t, err)
 	assert.Equal(t, Closed, cb.State())
 
@@ -66,12 +69,12 @@ func TestTimeClosedFailureStaysClosed(t *testing.T) {
 	t.Parallel()
 
 	start := time.Now()
-	clock := NewTestClock(start, 2*time.Millisecond)
+	clock := circuittest.NewClock(start)
 	openTimeout := time.Millisecond
-	halfOpenProbesThreshold := uint8(1)
-	closedFailuresThreshold := uint8(2)
+	halfOpenProbesThreshold := 1
+	closedFailuresThreshold := 2
 
-	cb, err := NewTimeCB(clock, openTimeout, halfOpenProbesThreshold, closedFailuresThreshold)
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(openTimeout), WithHalfOpenFailureThreshold(halfOpenProbesThreshold), WithFailureThreshold(closedFailuresThreshold))
 	assert.NoError(t, err)
 	assert.Equal(t, Closed, cb.State())
 
@@ -84,12 +87,12 @@ func TestTimeClosedToOpen(t *testing.T) {
 	t.Parallel()
 
 	start := time.Now()
-	clock := NewTestClock(start, 2*time.Millisecond)
+	clock := circuittest.NewClock(start)
 	openTimeout := time.Millisecond
-	halfOpenProbesThreshold := uint8(1)
-	closedFailuresThreshold := uint8(2)
+	halfOpenProbesThreshold := 1
+	closedFailuresThreshold := 2
 
-	cb, err := NewTimeCB(clock, openTimeout, halfOpenProbesThreshold, closedFailuresThreshold)
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(openTimeout), WithHalfOpenFailureThreshold(halfOpenProbesThreshold), WithFailureThreshold(closedFailuresThreshold))
 	assert.NoError(t, err)
 	assert.Equal(t, Closed, cb.State())
 
@@ -106,12 +109,12 @@ func TestTimeOpenRejectsCallsImmediately(t *testing.T) {
 	t.Parallel()
 
 	start := time.Now()
-	clock := NewTestClock(start, 2*time.Millisecond)
+	clock := circuittest.NewClock(start)
 	openTimeout := time.Millisecond
-	halfOpenProbesThreshold := uint8(1)
-	closedFailuresThreshold := uint8(2)
+	halfOpenProbesThreshold := 1
+	closedFailuresThreshold := 2
 
-	cb, err := NewTimeCB(clock, openTimeout, halfOpenProbesThreshold, closedFailuresThreshold)
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(openTimeout), WithHalfOpenFailureThreshold(halfOpenProbesThreshold), WithFailureThreshold(closedFailuresThreshold))
 	assert.NoError(t, err)
 	assert.Equal(t, Closed, cb.State())
 
@@ -132,12 +135,12 @@ func TestTimeOpenRejectsUntilTimeoutThenAllowsHalfOpenCall(t *testing.T) {
 	t.Parallel()
 
 	start := time.Now()
-	clock := NewTestClock(start, 2*time.Millisecond)
+	clock := circuittest.NewClock(start)
 	openTimeout := time.Millisecond
-	halfOpenProbesThreshold := uint8(1)
-	closedFailuresThreshold := uint8(2)
+	halfOpenProbesThreshold := 1
+	closedFailuresThreshold := 2
 
-	cb, err := NewTimeCB(clock, openTimeout, halfOpenProbesThreshold, closedFailuresThreshold)
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(openTimeout), WithHalfOpenFailureThreshold(halfOpenProbesThreshold), WithFailureThreshold(closedFailuresThreshold))
 	assert.NoError(t, err)
 	assert.Equal(t, Closed, cb.State())
 
@@ -145,7 +148,7 @@ func TestTimeOpenRejectsUntilTimeoutThenAllowsHalfOpenCall(t *testing.T) {
 	assert.Equal(t, Failed, result)
 	assert.Equal(t, Closed, cb.State())
 
-	clock.Tick()
+	clock.Advance(2 * time.Millisecond)
 
 	result = cb.Call(Error(t))
 	assert.Equal(t, Failed, result)
@@ -155,7 +158,7 @@ func TestTimeOpenRejectsUntilTimeoutThenAllowsHalfOpenCall(t *testing.T) {
 	assert.Equal(t, Rejected, result)
 	assert.Equal(t, Open, cb.State())
 
-	clock.Tick()
+	clock.Advance(2 * time.Millisecond)
 
 	result = cb.Call(Ok(t))
 	assert.Equal(t, Succeeded, result)
@@ -166,12 +169,12 @@ func TestTimeHalfOpenSuccessClosesBreaker(t *testing.T) {
 	t.Parallel()
 
 	start := time.Now()
-	clock := NewTestClock(start, 2*time.Millisecond)
+	clock := circuittest.NewClock(start)
 	openTimeout := time.Millisecond
-	halfOpenProbesThreshold := uint8(1)
-	closedFailuresThreshold := uint8(2)
+	halfOpenProbesThreshold := 1
+	closedFailuresThreshold := 2
 
-	cb, err := NewTimeCB(clock, openTimeout, halfOpenProbesThreshold, closedFailuresThreshold)
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(openTimeout), WithHalfOpenFailureThreshold(halfOpenProbesThreshold), WithFailureThreshold(closedFailuresThreshold))
 	assert.NoError(t, err)
 	assert.Equal(t, Closed, cb.State())
 
@@ -179,7 +182,7 @@ func TestTimeHalfOpenSuccessClosesBreaker(t *testing.T) {
 	assert.Equal(t, Failed, result)
 	assert.Equal(t, Closed, cb.State())
 
-	clock.Tick()
+	clock.Advance(2 * time.Millisecond)
 
 	result = cb.Call(Error(t))
 	assert.Equal(t, Failed, result)
@@ -189,7 +192,7 @@ func TestTimeHalfOpenSuccessClosesBreaker(t *testing.T) {
 	assert.Equal(t, Rejected, result)
 	assert.Equal(t, Open, cb.State())
 
-	clock.Tick()
+	clock.Advance(2 * time.Millisecond)
 
 	result = cb.Call(Ok(t))
 	assert.Equal(t, Succeeded, result)
@@ -200,12 +203,12 @@ func TestTimeHalfOpenFailureOpensBreaker(t *testing.T) {
 	t.Parallel()
 
 	start := time.Now()
-	clock := NewTestClock(start, 2*time.Millisecond)
+	clock := circuittest.NewClock(start)
 	openTimeout := time.Millisecond
-	halfOpenProbesThreshold := uint8(1)
-	closedFailuresThreshold := uint8(2)
+	halfOpenProbesThreshold := 1
+	closedFailuresThreshold := 2
 
-	cb, err := NewTimeCB(clock, openTimeout, halfOpenProbesThreshold, closedFailuresThreshold)
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(openTimeout), WithHalfOpenFailureThreshold(halfOpenProbesThreshold), WithFailureThreshold(closedFailuresThreshold))
 	assert.NoError(t, err)
 	assert.Equal(t, Closed, cb.State())
 
@@ -213,13 +216,13 @@ func TestTimeHalfOpenFailureOpensBreaker(t *testing.T) {
 	assert.Equal(t, Failed, result)
 	assert.Equal(t, Closed, cb.State())
 
-	clock.Tick()
+	clock.Advance(2 * time.Millisecond)
 
 	result = cb.Call(Error(t))
 	assert.Equal(t, Failed, result)
 	assert.Equal(t, Open, cb.State())
 
-	clock.Tick()
+	clock.Advance(2 * time.Millisecond)
 
 	result = cb.Call(Error(t))
 	assert.Equal(t, Failed, result)
@@ -230,11 +233,11 @@ func TestTimeHalfOpenRespectsProbesThreshold(t *testing.T) {
 	t.Parallel()
 
 	start := time.Now()
-	clock := NewTestClock(start, 2*time.Millisecond)
-	halfOpenProbesThreshold := uint8(2)
-	closedFailuresThreshold := uint8(2)
+	clock := circuittest.NewClock(start)
+	halfOpenProbesThreshold := 2
+	closedFailuresThreshold := 2
 
-	cb, err := NewTimeCB(clock, time.Millisecond, halfOpenProbesThreshold, closedFailuresThreshold)
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithHalfOpenFailureThreshold(halfOpenProbesThreshold), WithFailureThreshold(closedFailuresThreshold))
 	assert.NoError(t, err)
 	assert.Equal(t, Closed, cb.State())
 
@@ -242,13 +245,13 @@ func TestTimeHalfOpenRespectsProbesThreshold(t *testing.T) {
 	assert.Equal(t, Failed, result)
 	assert.Equal(t, Closed, cb.State())
 
-	clock.Tick()
+	clock.Advance(2 * time.Millisecond)
 
 	result = cb.Call(Error(t))
 	assert.Equal(t, Failed, result)
 	assert.Equal(t, Open, cb.State())
 
-	clock.Tick()
+	clock.Advance(2 * time.Millisecond)
 
 	result = cb.Call(Error(t))
 	assert.Equal(t, Failed, result)
@@ -262,3 +265,54 @@ func TestTimeHalfOpenRespectsProbesThreshold(t *testing.T) {
 	assert.Equal(t, Rejected, result)
 	assert.Equal(t, Open, cb.State())
 }
+
+func TestTimeHalfOpenClockGoesBack(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	clock := circuittest.NewClock(start)
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(time.Second), WithFailureThreshold(1))
+	assert.NoError(t, err)
+
+	assert.Equal(t, Failed, cb.Call(Error(t)))
+	clock.Advance(2 * time.Second)
+	done, ok := cb.Allow()
+	assert.True(t, ok)
+	assert.Equal(t, HalfOpen, cb.State())
+
+	clock.Set(start)
+	assert.Equal(t, TooManyProbes, cb.Call(Ok(t)))
+	done(nil)
+	assert.Equal(t, Closed, cb.State())
+	assert.Equal(t, Succeeded, cb.Call(Ok(t)))
+}
+
+func TestTimeStaleOutcomeIsIgnored(t *testing.T) {
+	t.Parallel()
+
+	clock := circuittest.NewClock(time.Now())
+	cb, err := NewTimeCB(WithClock(clock), WithOpenTimeout(time.Millisecond), WithHalfOpenFailureThreshold(1), WithFailureThreshold(2))
+	assert.NoError(t, err)
+
+	result := cb.Call(func() error {
+		assert.Equal(t, Failed, cb.Call(Error(t)))
+		assert.Equal(t, Failed, cb.Call(Error(t)))
+		assert.Equal(t, Open, cb.State())
+		return nil
+	})
+	assert.Equal(t, Succeeded, result)
+	assert.Equal(t, Open, cb.State())
+}
+
+func TestRealClockAfterFunc(t *testing.T) {
+	t.Parallel()
+
+	clock := &RealClock{}
+	stop := clock.AfterFunc(time.Hour, func() {})
+	assert.True(t, stop())
+
+	fired := make(chan struct{})
+	stop = clock.AfterFunc(time.Millisecond, func() { close(fired) })
+	<-fired
+	assert.False(t, stop())
+}
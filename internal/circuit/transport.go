@@ -0,0 +1,106 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ResponseClassifier decides what the response or transport error of a
+// round trip says about the health of the host.
+type ResponseClassifier func(resp *http.Response, err error) Outcome
+
+// DefaultResponseClassifier counts transport errors and 5xx responses as
+// failures, anything else, 4xx included, is a success. Requests canceled by
+// the caller are ignored.
+func DefaultResponseClassifier(resp *http.Response, err error) Outcome {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return OutcomeIgnore
+	case err != nil, resp.StatusCode >= http.StatusInternalServerError:
+		return OutcomeFailure
+	default:
+		return OutcomeSuccess
+	}
+}
+
+// Transport is an http.RoundTripper that runs every request through the
+// breaker of its host. Requests to a host whose breaker rejects them fail
+// with a *RejectedError without reaching the base transport. Responses are
+// returned unchanged, even the ones counted as failures.
+type Transport struct {
+	base     http.RoundTripper
+	classify ResponseClassifier
+	breaker  func(host string) (Breaker, error)
+}
+
+type TransportOption func(*Transport)
+
+// WithBaseTransport sets the transport that performs the requests, it
+// defaults to http.DefaultTransport.
+func WithBaseTransport(base http.RoundTripper) TransportOption {
+	return func(t *Transport) {
+		t.base = base
+	}
+}
+
+// WithResponseClassifier replaces DefaultResponseClassifier. It takes the
+// place of the Classifier of the breakers for requests made through the
+// transport.
+func WithResponseClassifier(classify ResponseClassifier) TransportOption {
+	return func(t *Transport) {
+		t.classify = classify
+	}
+}
+
+// NewTransport picks the breaker of each request from registry by the host,
+// and port if any, of the request URL.
+func NewTransport[B Breaker](registry *Registry[B], opts ...TransportOption) *Transport {
+	t := &Transport{
+		base:     http.DefaultTransport,
+		classify: DefaultResponseClassifier,
+		breaker: func(host string) (Breaker, error) {
+			return registry.Breaker(host)
+		},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// errNoHost matches the error of http.Transport, requests without a host have
+// no breaker to go through.
+var errNoHost = errors.New("circuit: no Host in request URL")
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "" {
+		closeBody(req)
+		return nil, errNoHost
+	}
+
+	b, err := t.breaker(req.URL.Host)
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+
+	var resp *http.Response
+	_, rejected := attempt(b, func() error {
+		resp, err = t.base.RoundTrip(req)
+		return &classified{outcome: t.classify(resp, err), err: err}
+	})
+	if rejected != nil {
+		closeBody(req)
+		return nil, rejected
+	}
+	return resp, err
+}
+
+// closeBody closes the body of a request that is never sent, RoundTrip must
+// close it even then.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
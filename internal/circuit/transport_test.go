@@ -0,0 +1,367 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testServer struct {
+	*httptest.Server
+	hits atomic.Int32
+}
+
+func newTestServer(t *testing.T, status int) *testServer {
+	t.Helper()
+	s := &testServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		s.hits.Add(1)
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestClient(t *testing.T, opts ...TransportOption) (*http.Client, *Registry[*CountCB]) {
+	t.Helper()
+	registry, err := NewRegistry(NewCountCB, WithFailureThreshold(1))
+	assert.NoError(t, err)
+	return &http.Client{Transport: NewTransport(registry, opts...)}, registry
+}
+
+func get(ctx context.Context, client *http.Client, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, err
+}
+
+func host(t *testing.T, s *testServer) string {
+	t.Helper()
+	u, err := url.Parse(s.URL)
+	assert.NoError(t, err)
+	return u.Host
+}
+
+func TestTransportServerErrorOpensBreaker(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, http.StatusInternalServerError)
+	client, registry := newTestClient(t)
+
+	// The failed response still reaches the caller.
+	status, err := get(context.Background(), client, server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, Open, registry.Get(host(t, server)).State())
+
+	_, err = get(context.Background(), client, server.URL)
+	var rejected *RejectedError
+	assert.ErrorAs(t, err, &rejected)
+	assert.Equal(t, Open, rejected.State)
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.Equal(t, int32(1), server.hits.Load())
+}
+
+func TestTransportClientErrorIsSuccess(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, http.StatusNotFound)
+	client, registry := newTestClient(t)
+
+	for range 3 {
+		status, err := get(context.Background(), client, server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, status)
+	}
+
+	stats := registry.Get(host(t, server)).Stats()
+	assert.Equal(t, Closed, stats.State)
+	assert.Equal(t, uint64(3), stats.Successes)
+	assert.Equal(t, int32(3), server.hits.Load())
+}
+
+// outsideBreaker is a Breaker as another package would write it, it only
+// sees what f returns and keeps the errors it got.
+type outsideBreaker struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func newOutsideBreaker(...Option) (*outsideBreaker, error) {
+	return &outsideBreaker{}, nil
+}
+
+func (b *outsideBreaker) Call(f func() error) Result {
+	err := f()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errs = append(b.errs, err)
+	if err != nil {
+		return Failed
+	}
+	return Succeeded
+}
+
+func (b *outsideBreaker) CallContext(ctx context.Context, f func(context.Context) error) Result {
+	return callContext(ctx, b, f)
+}
+
+func (b *outsideBreaker) Execute(f func() error) error {
+	var err error
+	b.Call(func() error {
+		err = f()
+		return err
+	})
+	return err
+}
+
+func (b *outsideBreaker) ExecuteContext(ctx context.Context, f func(context.Context) error) error {
+	return executeContext(ctx, b, f)
+}
+
+func (b *outsideBreaker) CallWithFallback(f func() error, _ Fallback) error {
+	return b.Execute(f)
+}
+
+func (b *outsideBreaker) failures() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	failures := 0
+	for _, err := range b.errs {
+		if err != nil {
+			failures++
+		}
+	}
+	return failures
+}
+
+func (b *outsideBreaker) State() State                             { return Closed }
+func (b *outsideBreaker) Stats() Stats                             { return Stats{} }
+func (b *outsideBreaker) OnStateChange(StateChangeListener) func() { return func() {} }
+func (b *outsideBreaker) ForceOpen()                               {}
+func (b *outsideBreaker) ForceClosed()                             {}
+func (b *outsideBreaker) Reset()                                   {}
+func (b *outsideBreaker) Allow() (func(err error), bool)           { return func(error) {}, true }
+
+func TestTransportOutsideBreaker(t *testing.T) {
+	t.Parallel()
+
+	healthy := newTestServer(t, http.StatusOK)
+	missing := newTestServer(t, http.StatusNotFound)
+	failing := newTestServer(t, http.StatusBadGateway)
+	registry, err := NewRegistry(newOutsideBreaker)
+	assert.NoError(t, err)
+	client := &http.Client{Transport: NewTransport(registry)}
+
+	for _, server := range []*testServer{healthy, missing, failing} {
+		_, err := get(context.Background(), client, server.URL)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 0, registry.Get(host(t, healthy)).failures())
+	assert.Equal(t, 0, registry.Get(host(t, missing)).failures())
+	assert.Equal(t, 1, registry.Get(host(t, failing)).failures())
+	assert.Equal(t, []error{errClassifiedFailure}, registry.Get(host(t, failing)).errs)
+}
+
+func TestTransportBreakerPerHost(t *testing.T) {
+	t.Parallel()
+
+	failing := newTestServer(t, http.StatusBadGateway)
+	healthy := newTestServer(t, http.StatusOK)
+	client, registry := newTestClient(t)
+
+	_, _ = get(context.Background(), client, failing.URL)
+	_, err := get(context.Background(), client, failing.URL)
+	assert.ErrorIs(t, err, ErrOpen)
+
+	status, err := get(context.Background(), client, healthy.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	assert.ElementsMatch(t, []string{host(t, failing), host(t, healthy)}, registry.Names())
+}
+
+func TestTransportErrorIsFailure(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, http.StatusOK)
+	server.Close()
+	client, registry := newTestClient(t)
+
+	_, err := get(context.Background(), client, server.URL)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrOpen)
+
+	stats := registry.Get(host(t, server)).Stats()
+	assert.Equal(t, Open, stats.State)
+	assert.Equal(t, uint64(1), stats.Failures)
+}
+
+func TestTransportCanceledIsIgnored(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, http.StatusOK)
+	client, registry := newTestClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := get(ctx, client, server.URL)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	stats := registry.Get(host(t, server)).Stats()
+	assert.Equal(t, Closed, stats.State)
+	assert.Zero(t, stats.Failures)
+	assert.Equal(t, uint64(1), stats.Ignored)
+}
+
+func TestTransportDeadlineIsTimedOut(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	client, registry := newTestClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := get(ctx, client, server.URL)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	u, _ := url.Parse(server.URL)
+	assert.Equal(t, uint64(1), registry.Get(u.Host).Stats().TimedOut)
+}
+
+func TestTransportResponseClassifier(t *testing.T) {
+	t.Parallel()
+
+	tooMany := newTestServer(t, http.StatusTooManyRequests)
+	unavailable := newTestServer(t, http.StatusServiceUnavailable)
+	client, registry := newTestClient(t, WithResponseClassifier(func(resp *http.Response, err error) Outcome {
+		switch {
+		case err != nil, resp.StatusCode == http.StatusTooManyRequests:
+			return OutcomeFailure
+		case resp.StatusCode == http.StatusServiceUnavailable:
+			return OutcomeIgnore
+		default:
+			return OutcomeSuccess
+		}
+	}))
+
+	_, _ = get(context.Background(), client, tooMany.URL)
+	assert.Equal(t, Open, registry.Get(host(t, tooMany)).State())
+
+	for range 3 {
+		status, err := get(context.Background(), client, unavailable.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+	}
+	stats := registry.Get(host(t, unavailable)).Stats()
+	assert.Equal(t, Closed, stats.State)
+	assert.Equal(t, uint64(3), stats.Ignored)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportBaseTransport(t *testing.T) {
+	t.Parallel()
+
+	failure := errors.New("dial failed")
+	client, registry := newTestClient(t, WithBaseTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, failure
+	})))
+
+	_, err := get(context.Background(), client, "http://backend.internal:8080/users")
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, Open, registry.Get("backend.internal:8080").State())
+}
+
+func TestDefaultResponseClassifier(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, OutcomeFailure, DefaultResponseClassifier(nil, errors.New("reset")))
+	assert.Equal(t, OutcomeIgnore, DefaultResponseClassifier(nil, context.Canceled))
+	for status, outcome := range map[int]Outcome{
+		http.StatusOK:                  OutcomeSuccess,
+		http.StatusFound:               OutcomeSuccess,
+		http.StatusBadRequest:          OutcomeSuccess,
+		http.StatusTooManyRequests:     OutcomeSuccess,
+		http.StatusInternalServerError: OutcomeFailure,
+		http.StatusGatewayTimeout:      OutcomeFailure,
+	} {
+		assert.Equal(t, outcome, DefaultResponseClassifier(&http.Response{StatusCode: status}, nil), status)
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed atomic.Bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed.Store(true)
+	return nil
+}
+
+func TestTransportRejectionClosesRequestBody(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, http.StatusOK)
+	client, registry := newTestClient(t)
+	registry.Get(host(t, server)).ForceOpen()
+
+	body := &closeRecorder{Reader: strings.NewReader("payload")}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL, body)
+	assert.NoError(t, err)
+
+	resp, err := client.Transport.RoundTrip(req)
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.True(t, body.closed.Load())
+	assert.Equal(t, int32(0), server.hits.Load())
+}
+
+func TestTransportRequiresHost(t *testing.T) {
+	t.Parallel()
+
+	registry, err := NewRegistry(NewCountCB, WithStore(NewMemoryStore()))
+	assert.NoError(t, err)
+	transport := NewTransport(registry, WithBaseTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		t.Fatal("request sent")
+		return nil, nil
+	})))
+
+	body := &closeRecorder{Reader: strings.NewReader("payload")}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http:///users", body)
+	assert.NoError(t, err)
+
+	resp, err := transport.RoundTrip(req)
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, errNoHost)
+	assert.True(t, body.closed.Load())
+	assert.Empty(t, registry.Names())
+}
@@ -0,0 +1,137 @@
+package circuit
+
+import "time"
+
+type window interface {
+	record(now time.Time, failed, slow bool)
+	counts(now time.Time) (total, failures, slow int)
+	reset()
+}
+
+type sample struct {
+	failed bool
+	slow   bool
+}
+
+// countWindow is a ring buffer holding the outcomes of the last
+// len(samples) calls.
+type countWindow struct {
+	samples  []sample
+	next     int
+	total    int
+	failures int
+	slow     int
+}
+
+func newCountWindow(size int) *countWindow {
+	return &countWindow{samples: make([]sample, size)}
+}
+
+func (w *countWindow) record(_ time.Time, failed, slow bool) {
+	if w.total == len(w.samples) {
+		evicted := w.samples[w.next]
+		if evicted.failed {
+			w.failures--
+		}
+		if evicted.slow {
+			w.slow--
+		}
+	} else {
+		w.total++
+	}
+
+	w.samples[w.next] = sample{failed: failed, slow: slow}
+	if failed {
+		w.failures++
+	}
+	if slow {
+		w.slow++
+	}
+	w.next = (w.next + 1) % len(w.samples)
+
+	asserts(w.total <= len(w.samples))
+	asserts(0 <= w.failures && w.failures <= w.total)
+	asserts(0 <= w.slow && w.slow <= w.total)
+}
+
+func (w *countWindow) counts(_ time.Time) (int, int, int) {
+	return w.total, w.failures, w.slow
+}
+
+func (w *countWindow) reset() {
+	clear(w.samples)
+	w.next = 0
+	w.total = 0
+	w.failures = 0
+	w.slow = 0
+}
+
+type bucket struct {
+	epoch    int64
+	total    int
+	failures int
+	slow     int
+}
+
+// timeWindow splits a duration of clock time into fixed width buckets. A
+// bucket is identified by its epoch, the number of widths since the Unix
+// epoch, so a bucket older than the window is recognized and skipped on read
+// without anything having to sweep it.
+type timeWindow struct {
+	buckets []bucket
+	width   time.Duration
+}
+
+func newTimeWindow(duration time.Duration, buckets int) *timeWindow {
+	return &timeWindow{
+		buckets: make([]bucket, buckets),
+		width:   duration / time.Duration(buckets),
+	}
+}
+
+func (w *timeWindow) epoch(now time.Time) int64 {
+	return now.UnixNano() / int64(w.width)
+}
+
+func (w *timeWindow) slot(epoch int64) *bucket {
+	n := int64(len(w.buckets))
+	return &w.buckets[((epoch%n)+n)%n]
+}
+
+func (w *timeWindow) live(b *bucket, epoch int64) bool {
+	return b.epoch <= epoch && epoch-b.epoch < int64(len(w.buckets))
+}
+
+func (w *timeWindow) record(now time.Time, failed, slow bool) {
+	epoch := w.epoch(now)
+	b := w.slot(epoch)
+	if b.epoch != epoch {
+		*b = bucket{epoch: epoch}
+	}
+
+	b.total++
+	if failed {
+		b.failures++
+	}
+	if slow {
+		b.slow++
+	}
+}
+
+func (w *timeWindow) counts(now time.Time) (int, int, int) {
+	epoch := w.epoch(now)
+	total, failures, slow := 0, 0, 0
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if w.live(b, epoch) {
+			total += b.total
+			failures += b.failures
+			slow += b.slow
+		}
+	}
+	return total, failures, slow
+}
+
+func (w *timeWindow) reset() {
+	clear(w.buckets)
+}
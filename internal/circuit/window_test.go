@@ -0,0 +1,103 @@
+package circuit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountWindowSlides(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	w := newCountWindow(3)
+	w.record(now, true, false)
+	w.record(now, true, false)
+	w.record(now, false, false)
+	total, failures, _ := w.counts(now)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 2, failures)
+
+	w.record(now, false, false)
+	total, failures, _ = w.counts(now)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 1, failures)
+
+	w.reset()
+	total, failures, _ = w.counts(now)
+	assert.Equal(t, 0, total)
+	assert.Equal(t, 0, failures)
+}
+
+func TestTimeWindowExpiresBuckets(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_000, 0)
+	w := newTimeWindow(3*time.Second, 3)
+
+	w.record(now, true, false)
+	w.record(now.Add(time.Second), false, false)
+	w.record(now.Add(2*time.Second), true, false)
+
+	total, failures, _ := w.counts(now.Add(2 * time.Second))
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 2, failures)
+
+	total, failures, _ = w.counts(now.Add(3 * time.Second))
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 1, failures)
+
+	total, failures, _ = w.counts(now.Add(time.Hour))
+	assert.Equal(t, 0, total)
+	assert.Equal(t, 0, failures)
+}
+
+func TestTimeWindowReusesSlot(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_000, 0)
+	w := newTimeWindow(2*time.Second, 2)
+
+	w.record(now, true, false)
+	w.record(now.Add(2*time.Second), false, false)
+
+	total, failures, _ := w.counts(now.Add(2 * time.Second))
+	assert.Equal(t, 1, total)
+	assert.Equal(t, 0, failures)
+
+	w.reset()
+	total, _, _ = w.counts(now.Add(2 * time.Second))
+	assert.Equal(t, 0, total)
+}
+
+func TestTimeWindowIgnoresFutureBuckets(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_000, 0)
+	w := newTimeWindow(2*time.Second, 2)
+
+	w.record(now.Add(time.Second), true, false)
+	total, _, _ := w.counts(now)
+	assert.Equal(t, 0, total)
+}
+
+func TestWindowsCountSlowCalls(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_000, 0)
+	for name, w := range map[string]window{"count": newCountWindow(2), "time": newTimeWindow(2*time.Second, 2)} {
+		w.record(now, false, true)
+		w.record(now, true, false)
+		total, failures, slow := w.counts(now)
+		assert.Equal(t, 2, total, name)
+		assert.Equal(t, 1, failures, name)
+		assert.Equal(t, 1, slow, name)
+	}
+
+	w := newCountWindow(1)
+	w.record(now, false, true)
+	w.record(now, false, false)
+	_, _, slow := w.counts(now)
+	assert.Equal(t, 0, slow)
+}